@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// app holds what every session of one invocation shares: the config and
+// the policy, guard, confirmation, redaction and audit state built from
+// it. Sessions must share one audit log to keep its hash chain intact.
+type app struct {
+	cfg     *config
+	policy  *toolPolicy
+	confirm *confirmer
+	guard   *sqlGuard
+	audit   *auditLog
+	redact  *redactor
+
+	catalogs *catalogCache // cached server catalogs
+	results  *resultCache  // cached tool results; nil with -no-cache
+	verbose  bool
+
+	socket   string // daemon socket path
+	noDaemon bool   // always connect directly
+
+	// Middleware wraps the transport of direct connections
+	middleware     []middleware // added with use
+	middlewareCfgs []middlewareConfig
+	buildOnce      sync.Once
+	configured     []middleware // built from middlewareCfgs
+	buildErr       error
+
+	closers []func() error // run by Close
+
+	headers headerList // from -header, sent to every server
+	tls     tlsConfig  // from the TLS flags, over each server's own
+}
+
+func newApp(cfg *config, redact *redactor) (*app, error) {
+	policy, err := newToolPolicy(cfg.Policy)
+	if err != nil {
+		return nil, err
+	}
+	audit, err := newAuditLog(cfg.Audit, redact)
+	if err != nil {
+		return nil, err
+	}
+	catalogs, err := newCatalogCache(cfg.Catalog)
+	if err != nil {
+		return nil, err
+	}
+	results, err := newResultCache(cfg.Cache)
+	if err != nil {
+		return nil, err
+	}
+	confirm := newConfirmer(cfg.Policy.Confirm)
+	confirm.redact = redact
+	a := &app{
+		cfg:     cfg,
+		policy:  policy,
+		confirm: confirm,
+		guard:   &sqlGuard{rules: cfg.SQLGuard},
+		audit:   audit,
+		redact:  redact,
+
+		catalogs: catalogs,
+		results:  results,
+	}
+	if err := a.addMiddleware(cfg.Middleware); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// connect opens a new session to the MCP server at url. When a daemon is
+// running the session is routed through it and reuses its warm connection.
+func (a *app) connect(url string) (*session, error) {
+	s := &session{app: a, server: url}
+	if !a.noDaemon {
+		if conn, err := dialDaemon(a.socket, url); err == nil {
+			log.Printf("Using MCP server %s through daemon", url)
+			s.conn = conn
+			return s, nil
+		}
+	}
+
+	opts, err := a.dialOptions(url)
+	if err != nil {
+		return nil, err
+	}
+	opts.onNotify = s.handleNotification
+	mcpClient, err := dialMCP(url, opts)
+	if err != nil {
+		return nil, err
+	}
+	s.conn = mcpClient
+	return s, nil
+}
+
+// dialMCP connects directly to the MCP server at url. The transport
+// follows the URL scheme.
+func dialMCP(url string, opts dialOptions) (*rpcMux, error) {
+	// Log which URL we're connecting to
+	log.Printf("Connecting to MCP server: %s", url)
+
+	// Create transport client
+	transportClient, err := newTransport(url, opts)
+	if err != nil {
+		return nil, fmt.Errorf("create transport client: %w", err)
+	}
+	transportClient = wrapTransport(transportClient, opts.middleware)
+
+	// Initialize MCP session
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	mux, err := newRPCMux(ctx, transportClient, defaultMaxInFlight, opts.onNotify)
+	if err != nil {
+		return nil, fmt.Errorf("create MCP client: %w", err)
+	}
+	return mux, nil
+}
+
+// onClose registers fn to run when the app is closed.
+func (a *app) onClose(fn func() error) {
+	a.closers = append(a.closers, fn)
+}
+
+func (a *app) Close() error {
+	for _, fn := range a.closers {
+		fn()
+	}
+	return a.audit.Close()
+}
@@ -0,0 +1,455 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"sync"
+	"time"
+)
+
+// auditConfig enables the append-only audit log.
+//
+//	"audit": {"file": "audit.jsonl", "maxSize": 10485760, "maxFiles": 5, "redact": ["password"]}
+type auditConfig struct {
+	// File receives one JSON record per line.
+	File string `json:"file,omitempty"`
+	// Syslog also sends every record to the local syslog daemon.
+	Syslog bool `json:"syslog,omitempty"`
+	// MaxSize is the size in bytes at which File is rotated. Zero means 10 MiB.
+	MaxSize int64 `json:"maxSize,omitempty"`
+	// MaxFiles is the number of rotated files kept. Zero means 5.
+	MaxFiles int `json:"maxFiles,omitempty"`
+	// Redact lists argument names whose values are not recorded.
+	Redact []string `json:"redact,omitempty"`
+}
+
+// auditRecord is one line of the audit log. Hash covers the record with
+// Hash empty and chains to the previous record through Prev.
+type auditRecord struct {
+	Time       time.Time              `json:"time"`
+	User       string                 `json:"user"`
+	Server     string                 `json:"server"`
+	Kind       string                 `json:"kind"` // "tool" or "resource"
+	Tool       string                 `json:"tool,omitempty"`
+	Resource   string                 `json:"resource,omitempty"`
+	Arguments  map[string]interface{} `json:"arguments,omitempty"`
+	ResultSize int                    `json:"resultSize"`
+	IsError    bool                   `json:"isError"`
+	Error      string                 `json:"error,omitempty"`
+	Cached     bool                   `json:"cached,omitempty"`
+	LatencyMS  float64                `json:"latencyMs"`
+	Prev       string                 `json:"prev"`
+	Hash       string                 `json:"hash"`
+}
+
+// auditSink receives each encoded record. syslog is the only sink besides
+// the file.
+type auditSink interface {
+	write(line []byte) error
+	close() error
+}
+
+// auditLog appends hash-chained records to a rotating file and any extra
+// sinks. A nil *auditLog records nothing. Several processes may share the
+// file: each record is appended under a lock on file.lock, after reading
+// the chain head back from disk.
+type auditLog struct {
+	cfg    auditConfig
+	user   string
+	redact *redactor
+
+	mu   sync.Mutex
+	file *os.File
+	lock *os.File
+	size int64
+	prev string
+	sink auditSink
+}
+
+// newAuditLog opens the configured audit outputs. Records are redacted with
+// base plus the audit's own fields, even when base is disabled.
+func newAuditLog(cfg auditConfig, base *redactor) (*auditLog, error) {
+	if cfg.File == "" && !cfg.Syslog {
+		return nil, nil
+	}
+	if cfg.MaxSize == 0 {
+		cfg.MaxSize = 10 << 20
+	}
+	if cfg.MaxFiles == 0 {
+		cfg.MaxFiles = 5
+	}
+
+	a := &auditLog{cfg: cfg, user: localUser(), redact: base.withFields(cfg.Redact)}
+	if cfg.File != "" {
+		// Refuse to start on a log whose last record is damaged
+		if _, err := chainHead(cfg.File); err != nil {
+			return nil, err
+		}
+		lock, err := os.OpenFile(cfg.File+".lock", os.O_WRONLY|os.O_CREATE, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("audit: %w", err)
+		}
+		a.lock = lock
+		if err := a.open(); err != nil {
+			lock.Close()
+			return nil, err
+		}
+	}
+	if cfg.Syslog {
+		sink, err := newSyslogSink()
+		if err != nil {
+			a.Close()
+			return nil, fmt.Errorf("audit: %w", err)
+		}
+		a.sink = sink
+	}
+	return a, nil
+}
+
+// localUser names the account running the client.
+func localUser() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+func (a *auditLog) open() error {
+	f, err := os.OpenFile(a.cfg.File, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("audit: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("audit: %w", err)
+	}
+	a.file, a.size = f, info.Size()
+	return nil
+}
+
+// sync catches up with other processes writing the log: it reopens the
+// file if one of them rotated it and reloads the size and chain head. The
+// caller holds the file lock.
+func (a *auditLog) sync() error {
+	info, err := a.file.Stat()
+	if err != nil {
+		return fmt.Errorf("audit: %w", err)
+	}
+	if cur, err := os.Stat(a.cfg.File); err != nil || !os.SameFile(info, cur) {
+		a.file.Close()
+		if err := a.open(); err != nil {
+			return err
+		}
+	} else {
+		a.size = info.Size()
+	}
+	prev, err := chainHead(a.cfg.File)
+	if err != nil {
+		return err
+	}
+	a.prev = prev
+	return nil
+}
+
+// rotate shifts file.N-1 to file.N down to file to file.1. The hash chain
+// continues into the new file.
+func (a *auditLog) rotate() error {
+	if err := a.file.Close(); err != nil {
+		return err
+	}
+	for i := a.cfg.MaxFiles - 1; i >= 1; i-- {
+		err := os.Rename(fmt.Sprintf("%s.%d", a.cfg.File, i), fmt.Sprintf("%s.%d", a.cfg.File, i+1))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	if err := os.Rename(a.cfg.File, a.cfg.File+".1"); err != nil {
+		return err
+	}
+	return a.open()
+}
+
+// record fills in the chain fields of rec and appends it.
+func (a *auditLog) record(rec *auditRecord) error {
+	if a == nil {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.file != nil {
+		if err := lockFile(a.lock); err != nil {
+			return fmt.Errorf("audit: lock: %w", err)
+		}
+		defer unlockFile(a.lock)
+		if err := a.sync(); err != nil {
+			return err
+		}
+	}
+
+	rec.User = a.user
+	rec.Arguments = a.redact.Args(rec.Arguments)
+	rec.Error = a.redact.Text(rec.Error)
+	rec.Prev = a.prev
+	rec.Hash = ""
+	hash, err := auditHash(rec)
+	if err != nil {
+		return err
+	}
+	rec.Hash = hash
+
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("audit: %w", err)
+	}
+	line = append(line, '\n')
+
+	if a.file != nil {
+		if a.size > 0 && a.size+int64(len(line)) > a.cfg.MaxSize {
+			if err := a.rotate(); err != nil {
+				return fmt.Errorf("audit: rotate: %w", err)
+			}
+		}
+		n, err := a.file.Write(line)
+		a.size += int64(n)
+		if err != nil {
+			return fmt.Errorf("audit: %w", err)
+		}
+	}
+	// The record is in the chain now, whatever happens to the sink
+	a.prev = hash
+	if a.sink != nil {
+		if err := a.sink.write(line); err != nil {
+			return fmt.Errorf("audit: syslog: %w", err)
+		}
+	}
+	return nil
+}
+
+// logCall records a finished tool call or resource read. A zero start
+// means the request never reached the server.
+func (a *auditLog) logCall(rec *auditRecord, start time.Time, result interface{}, callErr error) error {
+	if a == nil {
+		return nil
+	}
+	rec.Time = time.Now().UTC()
+	if !start.IsZero() {
+		rec.Time = start.UTC()
+		rec.LatencyMS = float64(time.Since(start).Microseconds()) / 1000
+	}
+	if result != nil {
+		if data, err := json.Marshal(result); err == nil {
+			rec.ResultSize = len(data)
+		}
+		if r, ok := result.(*toolResult); ok {
+			rec.IsError = r.IsError
+		}
+	}
+	if callErr != nil {
+		rec.Error = callErr.Error()
+		rec.IsError = true
+	}
+	return a.record(rec)
+}
+
+// Close closes the file and any sink, returning the first error.
+func (a *auditLog) Close() error {
+	if a == nil {
+		return nil
+	}
+	var first error
+	keep := func(err error) {
+		if first == nil {
+			first = err
+		}
+	}
+	if a.file != nil {
+		keep(a.file.Close())
+	}
+	if a.lock != nil {
+		keep(a.lock.Close())
+	}
+	if a.sink != nil {
+		keep(a.sink.close())
+	}
+	return first
+}
+
+// auditHash returns the chain hash of rec, which must have Hash empty.
+func auditHash(rec *auditRecord) (string, error) {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return "", fmt.Errorf("audit: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// chainHead returns the hash the next record in path chains to: that of
+// the last record, or of the last rotated record when path is new.
+func chainHead(path string) (string, error) {
+	prev, err := lastAuditHash(path)
+	if err != nil || prev != "" {
+		return prev, err
+	}
+	return lastAuditHash(path + ".1")
+}
+
+// lastAuditHash returns the hash of the last record in path, or "" when the
+// file does not exist yet or is empty.
+func lastAuditHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("audit: %w", err)
+	}
+	defer f.Close()
+
+	last, err := lastLine(f)
+	if err != nil {
+		return "", fmt.Errorf("audit: %w", err)
+	}
+	if last == nil {
+		return "", nil
+	}
+	var rec auditRecord
+	if err := json.Unmarshal(last, &rec); err != nil {
+		return "", fmt.Errorf("audit: last record of %s: %w", path, err)
+	}
+	return rec.Hash, nil
+}
+
+// lastLine returns the last non-blank line of f, reading back from the
+// end so that long logs cost no more than short ones.
+func lastLine(f *os.File) ([]byte, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	var tail []byte
+	for end := info.Size(); end > 0; {
+		n := int64(64 * 1024)
+		if n > end {
+			n = end
+		}
+		buf := make([]byte, n)
+		if _, err := f.ReadAt(buf, end-n); err != nil {
+			return nil, err
+		}
+		tail = append(buf, tail...)
+		end -= n
+		trimmed := bytes.TrimRight(tail, " \t\r\n")
+		if i := bytes.LastIndexByte(trimmed, '\n'); i >= 0 {
+			return bytes.TrimSpace(trimmed[i+1:]), nil
+		}
+	}
+	if tail = bytes.TrimSpace(tail); len(tail) == 0 {
+		return nil, nil
+	}
+	return tail, nil
+}
+
+// auditFiles returns path and its rotated predecessors, oldest first.
+func auditFiles(path string) []string {
+	var files []string
+	for i := 1; ; i++ {
+		rotated := fmt.Sprintf("%s.%d", path, i)
+		if _, err := os.Stat(rotated); err != nil {
+			break
+		}
+		files = append([]string{rotated}, files...)
+	}
+	return append(files, path)
+}
+
+// verifyAudit walks the hash chain through files in order and reports the
+// number of records checked. The first record's Prev is trusted, since the
+// file it chains to may have been rotated away.
+func verifyAudit(w io.Writer, files []string) (int, error) {
+	var prev string
+	count := 0
+	for _, path := range files {
+		f, err := os.Open(path)
+		if err != nil {
+			return count, err
+		}
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), 16<<20)
+		for lineNo := 1; scanner.Scan(); lineNo++ {
+			line := bytes.TrimSpace(scanner.Bytes())
+			if len(line) == 0 {
+				continue
+			}
+			// Keep numbers as written so re-encoding reproduces the hash.
+			var rec auditRecord
+			dec := json.NewDecoder(bytes.NewReader(line))
+			dec.UseNumber()
+			if err := dec.Decode(&rec); err != nil {
+				f.Close()
+				return count, fmt.Errorf("%s:%d: %w", path, lineNo, err)
+			}
+			if count > 0 && rec.Prev != prev {
+				f.Close()
+				return count, fmt.Errorf("%s:%d: chain broken: prev %s, expected %s", path, lineNo, short(rec.Prev), short(prev))
+			}
+			want := rec.Hash
+			rec.Hash = ""
+			got, err := auditHash(&rec)
+			if err != nil {
+				f.Close()
+				return count, err
+			}
+			if got != want {
+				f.Close()
+				return count, fmt.Errorf("%s:%d: record was modified: hash %s, expected %s", path, lineNo, short(want), short(got))
+			}
+			prev = want
+			count++
+		}
+		err = scanner.Err()
+		f.Close()
+		if err != nil {
+			return count, fmt.Errorf("%s: %w", path, err)
+		}
+		fmt.Fprintf(w, "%s: ok\n", path)
+	}
+	return count, nil
+}
+
+func short(hash string) string {
+	if len(hash) > 12 {
+		return hash[:12]
+	}
+	if hash == "" {
+		return "(none)"
+	}
+	return hash
+}
+
+// runAuditCommand implements "audit verify FILE...".
+func runAuditCommand(args []string) error {
+	if len(args) < 2 || args[0] != "verify" {
+		return fmt.Errorf("usage: audit verify FILE...")
+	}
+	var files []string
+	for _, path := range args[1:] {
+		files = append(files, auditFiles(path)...)
+	}
+	count, err := verifyAudit(os.Stdout, files)
+	if err != nil {
+		return fmt.Errorf("verification failed after %d records: %w", count, err)
+	}
+	fmt.Printf("%d records verified\n", count)
+	return nil
+}
@@ -0,0 +1,10 @@
+//go:build windows || plan9
+
+package main
+
+import "os"
+
+// There is no flock here, so only writers within one process are ordered.
+func lockFile(f *os.File) error { return nil }
+
+func unlockFile(f *os.File) error { return nil }
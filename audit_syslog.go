@@ -0,0 +1,26 @@
+//go:build !windows && !plan9
+
+package main
+
+import "log/syslog"
+
+// syslogSink forwards audit records to the local syslog daemon.
+type syslogSink struct {
+	w *syslog.Writer
+}
+
+func newSyslogSink() (auditSink, error) {
+	w, err := syslog.New(syslog.LOG_INFO|syslog.LOG_AUTH, "mcp-client")
+	if err != nil {
+		return nil, err
+	}
+	return &syslogSink{w: w}, nil
+}
+
+func (s *syslogSink) write(line []byte) error {
+	return s.w.Info(string(line))
+}
+
+func (s *syslogSink) close() error {
+	return s.w.Close()
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestAuditLog(t *testing.T, path string, maxSize int64) *auditLog {
+	t.Helper()
+	a, err := newAuditLog(auditConfig{File: path, MaxSize: maxSize, MaxFiles: 10, Redact: []string{"password"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { a.Close() })
+	return a
+}
+
+func writeAuditRecords(t *testing.T, a *auditLog, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		rec := &auditRecord{Server: "http://db", Kind: "tool", Tool: "query", Arguments: map[string]interface{}{"n": i, "password": "secret"}}
+		if err := a.logCall(rec, time.Time{}, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestAuditVerify(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	writeAuditRecords(t, newTestAuditLog(t, path, 0), 5)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "secret") {
+		t.Error("redacted argument written to the audit log")
+	}
+	lines := strings.SplitAfter(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("got %d records, want 5", len(lines))
+	}
+
+	tests := []struct {
+		name  string
+		edit  func(lines []string) []string
+		count int
+		err   string
+	}{
+		{"intact", func(l []string) []string { return l }, 5, ""},
+		{"modified", func(l []string) []string {
+			l[2] = strings.Replace(l[2], `"tool":"query"`, `"tool":"drop"`, 1)
+			return l
+		}, 2, "record was modified"},
+		{"deleted", func(l []string) []string {
+			return append(l[:2:2], l[3:]...)
+		}, 2, "chain broken"},
+		{"reordered", func(l []string) []string {
+			l[1], l[2] = l[2], l[1]
+			return l
+		}, 1, "chain broken"},
+		{"truncated at the start", func(l []string) []string { return l[2:] }, 3, ""},
+		{"blank lines", func(l []string) []string { return append([]string{"\n"}, l...) }, 5, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			edited := filepath.Join(t.TempDir(), "audit.jsonl")
+			text := strings.Join(tt.edit(append([]string(nil), lines...)), "")
+			if err := os.WriteFile(edited, []byte(text), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			count, err := verifyAudit(io.Discard, []string{edited})
+			if count != tt.count {
+				t.Errorf("verified %d records, want %d", count, tt.count)
+			}
+			switch {
+			case tt.err == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Errorf("got error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestAuditChainAcrossRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	writeAuditRecords(t, newTestAuditLog(t, path, 600), 10)
+
+	files := auditFiles(path)
+	if len(files) < 3 {
+		t.Fatalf("got %d files, want the log to have rotated", len(files))
+	}
+	if count, err := verifyAudit(io.Discard, files); err != nil || count != 10 {
+		t.Fatalf("verified %d records: %v", count, err)
+	}
+}
+
+func TestAuditChainSharedFile(t *testing.T) {
+	// Two logs on one file stand in for two processes
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	a := newTestAuditLog(t, path, 1500)
+	b := newTestAuditLog(t, path, 1500)
+	for i := 0; i < 5; i++ {
+		writeAuditRecords(t, a, 1)
+		writeAuditRecords(t, b, 2)
+	}
+	files := auditFiles(path)
+	if len(files) < 2 {
+		t.Fatalf("got %d files, want the log to have rotated", len(files))
+	}
+	if count, err := verifyAudit(io.Discard, files); err != nil || count != 15 {
+		t.Fatalf("verified %d records: %v", count, err)
+	}
+
+	// A new log picks the chain up where the file ends
+	writeAuditRecords(t, newTestAuditLog(t, path, 1500), 1)
+	if count, err := verifyAudit(io.Discard, auditFiles(path)); err != nil || count != 16 {
+		t.Fatalf("verified %d records: %v", count, err)
+	}
+}
+
+type failingSink struct{ closed bool }
+
+func (s *failingSink) write([]byte) error { return errors.New("unreachable") }
+func (s *failingSink) close() error       { s.closed = true; return nil }
+
+func TestAuditSinkFailureKeepsChain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	a, err := newAuditLog(auditConfig{File: path}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink := &failingSink{}
+	a.sink = sink
+	for i := 0; i < 3; i++ {
+		err := a.record(&auditRecord{Kind: "tool", Tool: "query"})
+		if err == nil || !strings.Contains(err.Error(), "syslog") {
+			t.Fatalf("got %v, want a syslog error", err)
+		}
+	}
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !sink.closed {
+		t.Error("Close left the sink open")
+	}
+	if count, err := verifyAudit(io.Discard, []string{path}); err != nil || count != 3 {
+		t.Fatalf("verified %d records: %v", count, err)
+	}
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+)
+
+// batchRequest is one input line of the batch command.
+type batchRequest struct {
+	ID        json.RawMessage        `json:"id,omitempty"`
+	Tool      string                 `json:"tool"`
+	Arguments map[string]interface{} `json:"arguments,omitempty"`
+}
+
+// batchResult is one output line of the batch command. Status is "ok",
+// "tool_error" when the tool returned isError, or "error" when the call
+// failed or was refused.
+type batchResult struct {
+	Line       int             `json:"line"`
+	ID         json.RawMessage `json:"id,omitempty"`
+	Tool       string          `json:"tool"`
+	Status     string          `json:"status"`
+	Error      string          `json:"error,omitempty"`
+	Result     *toolResult     `json:"result,omitempty"`
+	Session    int             `json:"session"`
+	Started    time.Time       `json:"started"`
+	DurationMS float64         `json:"durationMs"`
+
+	seq int // position among the requests run in this invocation
+}
+
+// batchJob is a parsed input line waiting for a worker.
+type batchJob struct {
+	seq  int
+	line int
+	req  batchRequest
+	err  error // set when the line could not be parsed
+}
+
+// runBatch implements "batch [flags] [FILE]". Requests are read from FILE
+// or stdin, one JSON object per line:
+//
+//	{"tool": "standard_deviation", "arguments": {"table_name": "t", "column_name": "c"}}
+func runBatch(a *app, url string, args []string) error {
+	fs := flag.NewFlagSet("batch", flag.ExitOnError)
+	concurrency := fs.Int("concurrency", 4, "Maximum number of calls in flight")
+	sessions := fs.Int("sessions", 1, "Number of server sessions to spread calls over")
+	unordered := fs.Bool("unordered", false, "Write results as they complete instead of in input order")
+	outPath := fs.String("out", "", "Write result lines to this file instead of stdout")
+	resume := fs.Bool("resume", false, "Skip lines already completed in the -out file and append to it")
+	failFast := fs.Bool("fail-fast", false, "Stop starting new calls after the first failed one")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: batch [flags] [FILE]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *concurrency < 1 || *sessions < 1 {
+		return errors.New("batch: -concurrency and -sessions must be at least 1")
+	}
+	if *resume && *outPath == "" {
+		return errors.New("batch: -resume needs -out")
+	}
+
+	// Open the input
+	var in io.Reader = os.Stdin
+	if path := fs.Arg(0); path != "" && path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	// Find the lines an earlier run already completed
+	done := map[int]string{}
+	if *resume {
+		var err error
+		if done, err = completedBatchLines(*outPath); err != nil {
+			return err
+		}
+	}
+
+	// Open the output
+	var out io.Writer = os.Stdout
+	if *outPath != "" {
+		mode := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		if *resume {
+			mode = os.O_RDWR | os.O_CREATE | os.O_APPEND
+		}
+		f, err := os.OpenFile(*outPath, mode, 0o644)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if *resume {
+			if err := trimPartialLine(f); err != nil {
+				return err
+			}
+		}
+		out = f
+	}
+
+	// Connect the sessions
+	pool := make([]*session, *sessions)
+	for i := range pool {
+		sess, err := a.connect(url)
+		if err != nil {
+			return err
+		}
+		defer sess.Close()
+		pool[i] = sess
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := make(chan batchJob)
+	results := make(chan *batchResult)
+
+	// Read input lines and hand them to the workers
+	var readErr error
+	skipped := 0
+	go func() {
+		defer close(jobs)
+		scanner := bufio.NewScanner(in)
+		scanner.Buffer(make([]byte, 64*1024), 16<<20)
+		seq := 0
+		for line := 1; scanner.Scan(); line++ {
+			text := scanner.Bytes()
+			if len(bytes.TrimSpace(text)) == 0 {
+				continue
+			}
+			job := batchJob{line: line}
+			if err := json.Unmarshal(text, &job.req); err != nil {
+				job.err = fmt.Errorf("bad input line: %w", err)
+			} else if job.req.Tool == "" {
+				job.err = errors.New("bad input line: missing tool")
+			}
+			if tool, ok := done[line]; ok && job.err == nil && tool == job.req.Tool {
+				skipped++
+				continue
+			}
+			job.seq = seq
+			seq++
+			select {
+			case jobs <- job:
+			case <-ctx.Done():
+				return
+			}
+		}
+		readErr = scanner.Err()
+	}()
+
+	// Run the calls
+	var wg sync.WaitGroup
+	for w := 0; w < *concurrency; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			sessionIndex := w % len(pool)
+			for job := range jobs {
+				results <- runBatchJob(ctx, pool[sessionIndex], sessionIndex, job)
+			}
+		}(w)
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// Write results, holding back early ones when order is kept
+	enc := json.NewEncoder(out)
+	counts := map[string]int{}
+	pending := map[int]*batchResult{}
+	next := 0
+	var writeErr error
+	write := func(r *batchResult) {
+		counts[r.Status]++
+		if writeErr == nil {
+			writeErr = enc.Encode(r)
+		}
+	}
+	for r := range results {
+		if r.Status == "error" && *failFast {
+			cancel()
+		}
+		if *unordered {
+			write(r)
+			continue
+		}
+		pending[r.seq] = r
+		for pending[next] != nil {
+			write(pending[next])
+			delete(pending, next)
+			next++
+		}
+	}
+
+	log.Printf("Batch finished: %d ok, %d tool errors, %d failed, %d skipped as already done",
+		counts["ok"], counts["tool_error"], counts["error"], skipped)
+	if readErr != nil {
+		return fmt.Errorf("batch: read input: %w", readErr)
+	}
+	if writeErr != nil {
+		return fmt.Errorf("batch: write results: %w", writeErr)
+	}
+	if counts["error"] > 0 {
+		return fmt.Errorf("batch: %d calls failed", counts["error"])
+	}
+	return nil
+}
+
+// runBatchJob calls one tool and describes the outcome.
+func runBatchJob(ctx context.Context, sess *session, sessionIndex int, job batchJob) *batchResult {
+	r := &batchResult{
+		Line:    job.line,
+		ID:      job.req.ID,
+		Tool:    job.req.Tool,
+		Session: sessionIndex,
+		Started: time.Now().UTC(),
+		seq:     job.seq,
+	}
+	err := job.err
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err == nil {
+		var result *toolResult
+		result, err = sess.CallTool(ctx, job.req.Tool, job.req.Arguments)
+		r.Result = sess.redact.Result(result)
+	}
+	r.DurationMS = float64(time.Since(r.Started).Microseconds()) / 1000
+
+	switch {
+	case err != nil:
+		r.Status, r.Error = "error", sess.redact.Text(err.Error())
+	case r.Result.IsError:
+		r.Status = "tool_error"
+	default:
+		r.Status = "ok"
+	}
+	return r
+}
+
+// trimPartialLine truncates f after its last newline, dropping a line a
+// failed run was cut off in the middle of, so appended results start on a
+// line of their own.
+func trimPartialLine(f *os.File) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	end := info.Size()
+	for end > 0 {
+		n := int64(64 * 1024)
+		if n > end {
+			n = end
+		}
+		buf := make([]byte, n)
+		if _, err := f.ReadAt(buf, end-n); err != nil {
+			return err
+		}
+		if i := bytes.LastIndexByte(buf, '\n'); i >= 0 {
+			end -= n - int64(i) - 1
+			break
+		}
+		end -= n
+	}
+	if end == info.Size() {
+		return nil
+	}
+	return f.Truncate(end)
+}
+
+// completedBatchLines reads an earlier batch output and returns the tool
+// of every line that finished, either successfully or with a tool error.
+// A missing file means nothing has run yet.
+func completedBatchLines(path string) (map[int]string, error) {
+	done := map[int]string{}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return done, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64<<20)
+	for scanner.Scan() {
+		var r batchResult
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			continue // a line cut short by the failure being resumed from
+		}
+		if r.Status == "ok" || r.Status == "tool_error" {
+			done[r.Line] = r.Tool
+		} else {
+			delete(done, r.Line)
+		}
+	}
+	return done, scanner.Err()
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// catalogConfig controls the on-disk cache of server catalogs.
+//
+//	"catalog": {"ttl": "1h"}
+type catalogConfig struct {
+	// TTL is how long a cached list is used, as a Go duration. "0"
+	// disables the cache. The default is one hour.
+	TTL string `json:"ttl,omitempty"`
+	// Dir overrides the cache directory, which defaults to mcp-client/
+	// catalog under the user's cache directory.
+	Dir string `json:"dir,omitempty"`
+}
+
+// Kinds of cached lists
+const (
+	catalogTools     = "tools"
+	catalogResources = "resources"
+	catalogPrompts   = "prompts"
+)
+
+// catalogEntry is one cached list of tools, resources or prompts.
+type catalogEntry struct {
+	Server        string          `json:"server"`
+	ServerVersion string          `json:"serverVersion,omitempty"`
+	Fetched       time.Time       `json:"fetched"`
+	Items         json.RawMessage `json:"items"`
+}
+
+// catalogCache stores server catalogs per server and kind. Entries expire
+// after ttl and are dropped when the server reports a different version
+// or announces that a list changed.
+type catalogCache struct {
+	dir     string // empty when caching is disabled
+	ttl     time.Duration
+	refresh bool // ignore cached entries, but still store fresh ones
+}
+
+func newCatalogCache(cfg catalogConfig) (*catalogCache, error) {
+	c := &catalogCache{ttl: time.Hour, dir: cfg.Dir}
+	if cfg.TTL != "" {
+		ttl, err := time.ParseDuration(cfg.TTL)
+		if err != nil {
+			return nil, fmt.Errorf("catalog ttl: %w", err)
+		}
+		c.ttl = ttl
+	}
+	if c.ttl <= 0 {
+		c.dir = ""
+		return c, nil
+	}
+	if c.dir == "" {
+		base, err := os.UserCacheDir()
+		if err != nil {
+			// No cache directory, so no cache
+			return c, nil
+		}
+		c.dir = filepath.Join(base, "mcp-client", "catalog")
+	}
+	return c, nil
+}
+
+// path returns the file caching kind for server.
+func (c *catalogCache) path(server, kind string) string {
+	sum := sha256.Sum256([]byte(server))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:8]), kind+".json")
+}
+
+// load decodes the cached kind list for server into out and reports
+// whether a usable entry was found. version is the server's current
+// name/version; an empty version skips that check.
+func (c *catalogCache) load(server, kind, version string, out interface{}) bool {
+	if c == nil || c.dir == "" || c.refresh {
+		return false
+	}
+	data, err := os.ReadFile(c.path(server, kind))
+	if err != nil {
+		return false
+	}
+	var e catalogEntry
+	if err := json.Unmarshal(data, &e); err != nil || e.Server != server {
+		return false
+	}
+	if time.Since(e.Fetched) > c.ttl || (version != "" && e.ServerVersion != version) {
+		c.invalidate(server, kind)
+		return false
+	}
+	return json.Unmarshal(e.Items, out) == nil
+}
+
+// peek decodes the cached kind list for server into out whatever its age,
+// for shell completion, where stale names beat none.
+func (c *catalogCache) peek(server, kind string, out interface{}) bool {
+	if c == nil || c.dir == "" {
+		return false
+	}
+	data, err := os.ReadFile(c.path(server, kind))
+	if err != nil {
+		return false
+	}
+	var e catalogEntry
+	if err := json.Unmarshal(data, &e); err != nil || e.Server != server {
+		return false
+	}
+	return json.Unmarshal(e.Items, out) == nil
+}
+
+// store caches items as the kind list for server.
+func (c *catalogCache) store(server, kind, version string, items interface{}) error {
+	if c == nil || c.dir == "" {
+		return nil
+	}
+	data, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+	e := catalogEntry{Server: server, ServerVersion: version, Fetched: time.Now(), Items: data}
+	if data, err = json.Marshal(e); err != nil {
+		return err
+	}
+
+	return writeCacheFile(c.path(server, kind), data)
+}
+
+// writeCacheFile writes data to a temporary file next to path and renames
+// it into place, so concurrent readers never see half an entry.
+func writeCacheFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// invalidate drops the cached kind list for server, or every list when
+// kind is empty.
+func (c *catalogCache) invalidate(server, kind string) {
+	if c == nil || c.dir == "" {
+		return
+	}
+	if kind == "" {
+		os.RemoveAll(filepath.Dir(c.path(server, catalogTools)))
+		return
+	}
+	os.Remove(c.path(server, kind))
+}
+
+// listChangedHandler returns a notification handler that drops the
+// cached lists for server when it announces a change. onTools, if set, is
+// called as well when the tool list changes.
+func (c *catalogCache) listChangedHandler(server string, onTools func()) notificationHandler {
+	return func(ctx context.Context, method string, _ json.RawMessage) {
+		switch method {
+		case "notifications/tools/list_changed":
+			c.invalidate(server, catalogTools)
+			if onTools != nil {
+				onTools()
+			}
+		case "notifications/resources/list_changed":
+			c.invalidate(server, catalogResources)
+		case "notifications/prompts/list_changed":
+			c.invalidate(server, catalogPrompts)
+		}
+	}
+}
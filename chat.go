@@ -0,0 +1,439 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ThinkInAIXYZ/go-mcp/protocol"
+)
+
+// chatConfig points the chat command at an OpenAI-compatible chat
+// completions endpoint, such as a local llama.cpp, Ollama or vLLM server.
+//
+//	"chat": {"endpoint": "http://localhost:11434/v1", "model": "llama3.1", "maxIterations": 10}
+type chatConfig struct {
+	Endpoint string `json:"endpoint,omitempty"`
+	Model    string `json:"model,omitempty"`
+	// APIKeyEnv names the environment variable holding the API key.
+	APIKeyEnv string `json:"apiKeyEnv,omitempty"`
+	// System is the system prompt.
+	System string `json:"system,omitempty"`
+	// MaxIterations caps the model round trips for one user message.
+	MaxIterations int `json:"maxIterations,omitempty"`
+	// Confirm is "destructive" to ask before destructive tools only, as
+	// for -tool, or "all" to ask before every call the model makes.
+	Confirm string `json:"confirm,omitempty"`
+}
+
+func (c chatConfig) withDefaults() chatConfig {
+	if c.Endpoint == "" {
+		c.Endpoint = "http://localhost:11434/v1"
+	}
+	if c.APIKeyEnv == "" {
+		c.APIKeyEnv = "OPENAI_API_KEY"
+	}
+	if c.System == "" {
+		c.System = "You are a helpful assistant. Use the available tools to answer questions about the user's data."
+	}
+	if c.MaxIterations == 0 {
+		c.MaxIterations = 10
+	}
+	if c.Confirm == "" {
+		c.Confirm = "destructive"
+	}
+	return c
+}
+
+// maxToolResultChars caps the tool output sent back to the model.
+const maxToolResultChars = 20000
+
+// chatMessage is a message in the chat completions format.
+type chatMessage struct {
+	Role       string         `json:"role"`
+	Content    string         `json:"content"`
+	ToolCalls  []chatToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string         `json:"tool_call_id,omitempty"`
+}
+
+type chatToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// chatTool is a function definition offered to the model.
+type chatTool struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name        string          `json:"name"`
+		Description string          `json:"description,omitempty"`
+		Parameters  json.RawMessage `json:"parameters"`
+	} `json:"function"`
+}
+
+// chatFunctionName matches characters the API rejects in function names.
+var chatFunctionName = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// chatTools converts MCP tools into function definitions. The returned map
+// leads from function name back to tool name, since names the API rejects
+// are rewritten.
+func chatTools(tools []*protocol.Tool) ([]chatTool, map[string]string, error) {
+	defs := make([]chatTool, 0, len(tools))
+	names := map[string]string{}
+	for _, tool := range tools {
+		// Take the schema as the server sent it
+		data, err := json.Marshal(tool)
+		if err != nil {
+			return nil, nil, err
+		}
+		var raw struct {
+			InputSchema json.RawMessage `json:"inputSchema"`
+		}
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return nil, nil, err
+		}
+		if len(raw.InputSchema) == 0 || string(raw.InputSchema) == "null" {
+			raw.InputSchema = json.RawMessage(`{"type":"object","properties":{}}`)
+		}
+
+		// The API allows 64 characters, all ASCII after the rewrite
+		base := chatFunctionName.ReplaceAllString(tool.Name, "_")
+		if len(base) > 64 {
+			base = base[:64]
+		}
+		name := base
+		for i := 2; names[name] != ""; i++ {
+			suffix := fmt.Sprintf("_%d", i)
+			if len(base) > 64-len(suffix) {
+				base = base[:64-len(suffix)]
+			}
+			name = base + suffix
+		}
+		names[name] = tool.Name
+
+		var def chatTool
+		def.Type = "function"
+		def.Function.Name = name
+		def.Function.Description = tool.Description
+		def.Function.Parameters = raw.InputSchema
+		defs = append(defs, def)
+	}
+	return defs, names, nil
+}
+
+// chatClient streams chat completions from an OpenAI-compatible endpoint.
+type chatClient struct {
+	cfg    chatConfig
+	apiKey string
+	http   *http.Client
+}
+
+// complete sends the conversation and streams the reply. Text is passed
+// to onText as it arrives; tool calls are assembled from their fragments.
+func (c *chatClient) complete(ctx context.Context, messages []chatMessage, tools []chatTool, onText func(string)) (chatMessage, error) {
+	body := map[string]interface{}{
+		"model":    c.cfg.Model,
+		"messages": messages,
+		"stream":   true,
+	}
+	if len(tools) > 0 {
+		body["tools"] = tools
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return chatMessage{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(c.cfg.Endpoint, "/")+"/chat/completions", bytes.NewReader(data))
+	if err != nil {
+		return chatMessage{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return chatMessage{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return chatMessage{}, fmt.Errorf("chat endpoint: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	reply := chatMessage{Role: "assistant"}
+	var text strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 16<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		payload := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if payload == "[DONE]" {
+			break
+		}
+		var chunk struct {
+			Choices []struct {
+				Delta struct {
+					Content   string `json:"content"`
+					ToolCalls []struct {
+						Index    int    `json:"index"`
+						ID       string `json:"id"`
+						Function struct {
+							Name      string `json:"name"`
+							Arguments string `json:"arguments"`
+						} `json:"function"`
+					} `json:"tool_calls"`
+				} `json:"delta"`
+			} `json:"choices"`
+			Error *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(payload), &chunk); err != nil {
+			return chatMessage{}, fmt.Errorf("chat endpoint: bad stream chunk: %w", err)
+		}
+		if chunk.Error != nil {
+			return chatMessage{}, fmt.Errorf("chat endpoint: %s", chunk.Error.Message)
+		}
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+		delta := chunk.Choices[0].Delta
+		if delta.Content != "" {
+			text.WriteString(delta.Content)
+			onText(delta.Content)
+		}
+		for _, tc := range delta.ToolCalls {
+			for len(reply.ToolCalls) <= tc.Index {
+				reply.ToolCalls = append(reply.ToolCalls, chatToolCall{Type: "function"})
+			}
+			call := &reply.ToolCalls[tc.Index]
+			if tc.ID != "" {
+				call.ID = tc.ID
+			}
+			call.Function.Name += tc.Function.Name
+			call.Function.Arguments += tc.Function.Arguments
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return chatMessage{}, err
+	}
+	reply.Content = text.String()
+	for i := range reply.ToolCalls {
+		// Some local servers leave out call IDs
+		if reply.ToolCalls[i].ID == "" {
+			reply.ToolCalls[i].ID = fmt.Sprintf("call_%d", i)
+		}
+	}
+	return reply, nil
+}
+
+// lineWriter prints streamed text a line at a time, so redaction patterns
+// see whole lines.
+type lineWriter struct {
+	w       io.Writer
+	r       *redactor
+	pending string
+}
+
+func (lw *lineWriter) write(s string) {
+	lw.pending += s
+	for {
+		i := strings.IndexByte(lw.pending, '\n')
+		if i < 0 {
+			return
+		}
+		fmt.Fprintln(lw.w, lw.r.Text(lw.pending[:i]))
+		lw.pending = lw.pending[i+1:]
+	}
+}
+
+func (lw *lineWriter) flush() {
+	if lw.pending != "" {
+		fmt.Fprintln(lw.w, lw.r.Text(lw.pending))
+		lw.pending = ""
+	}
+}
+
+// runChatCommand implements "chat [flags] [MESSAGE]". With a message it
+// answers that one message; without, it reads messages from stdin until
+// EOF or /exit.
+func runChatCommand(a *app, url string, args []string) error {
+	cfg := a.cfg.Chat
+	fs := flag.NewFlagSet("chat", flag.ExitOnError)
+	fs.StringVar(&cfg.Endpoint, "endpoint", cfg.Endpoint, "OpenAI-compatible API base URL (default http://localhost:11434/v1)")
+	fs.StringVar(&cfg.Model, "model", cfg.Model, "Model name")
+	fs.StringVar(&cfg.System, "system", cfg.System, "System prompt")
+	fs.IntVar(&cfg.MaxIterations, "max-iterations", cfg.MaxIterations, "Model round trips allowed per message (default 10)")
+	fs.StringVar(&cfg.Confirm, "confirm", cfg.Confirm, "Ask before tool calls: destructive or all (default destructive)")
+	fs.Parse(args)
+	cfg = cfg.withDefaults()
+
+	if cfg.Model == "" {
+		return errors.New("chat: set -model or chat.model in the config file")
+	}
+	switch cfg.Confirm {
+	case "destructive":
+	case "all":
+		a.confirm.patterns = append(a.confirm.patterns, "*")
+	default:
+		return fmt.Errorf("chat: -confirm must be destructive or all, not %q", cfg.Confirm)
+	}
+
+	sess, err := a.connect(url)
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	tools, err := sess.ListTools(context.Background())
+	if err != nil {
+		return fmt.Errorf("list tools: %w", err)
+	}
+	defs, names, err := chatTools(tools)
+	if err != nil {
+		return err
+	}
+
+	c := &chatClient{cfg: cfg, apiKey: os.Getenv(cfg.APIKeyEnv), http: &http.Client{}}
+	messages := []chatMessage{{Role: "system", Content: cfg.System}}
+
+	turn := func(input string) error {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		messages = append(messages, chatMessage{Role: "user", Content: input})
+		var err error
+		messages, err = runChatTurn(ctx, a, sess, c, messages, defs, names)
+		if errors.Is(err, context.Canceled) {
+			fmt.Println("\n(interrupted)")
+			return nil
+		}
+		return err
+	}
+
+	if fs.NArg() > 0 {
+		return turn(strings.Join(fs.Args(), " "))
+	}
+
+	interactive := isTerminal(os.Stdin)
+	if interactive {
+		fmt.Printf("Chatting with %s using %d tools from %s. /exit to quit.\n", cfg.Model, len(defs), url)
+	}
+	// Read through the confirmer so typed-ahead answers are not lost
+	for {
+		if interactive {
+			fmt.Print("\n> ")
+		}
+		line, err := a.confirm.readLine()
+		if line == "" && err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		input := strings.TrimSpace(line)
+		switch input {
+		case "":
+			continue
+		case "/exit", "/quit":
+			return nil
+		}
+		if err := turn(input); err != nil {
+			return err
+		}
+	}
+}
+
+// runChatTurn runs the tool-call loop for the latest user message and
+// returns the conversation with the replies appended.
+func runChatTurn(ctx context.Context, a *app, sess *session, c *chatClient, messages []chatMessage, defs []chatTool, names map[string]string) ([]chatMessage, error) {
+	out := &lineWriter{w: os.Stdout, r: a.redact}
+	for i := 0; i < c.cfg.MaxIterations; i++ {
+		reply, err := c.complete(ctx, messages, defs, out.write)
+		out.flush()
+		if err != nil {
+			return messages, err
+		}
+		messages = append(messages, reply)
+		if len(reply.ToolCalls) == 0 {
+			return messages, nil
+		}
+
+		for _, call := range reply.ToolCalls {
+			content := runChatToolCall(ctx, a, sess, call, names)
+			messages = append(messages, chatMessage{Role: "tool", ToolCallID: call.ID, Content: content})
+		}
+	}
+	fmt.Printf("(stopped after %d model round trips; raise -max-iterations to allow more)\n", c.cfg.MaxIterations)
+	return messages, nil
+}
+
+// runChatToolCall runs one call the model asked for through the session,
+// so policy, guard, confirmation and audit apply, and returns what the
+// model is told.
+func runChatToolCall(ctx context.Context, a *app, sess *session, call chatToolCall, names map[string]string) string {
+	name, ok := names[call.Function.Name]
+	if !ok {
+		fmt.Printf("[tool] %s: unknown tool\n", call.Function.Name)
+		return fmt.Sprintf("error: there is no tool named %s", call.Function.Name)
+	}
+	args := map[string]interface{}{}
+	if s := strings.TrimSpace(call.Function.Arguments); s != "" {
+		if err := json.Unmarshal([]byte(s), &args); err != nil {
+			fmt.Printf("[tool] %s: arguments are not a JSON object\n", name)
+			return fmt.Sprintf("error: arguments must be a JSON object: %v", err)
+		}
+	}
+
+	shown, _ := json.Marshal(a.redact.Args(args))
+	fmt.Printf("[tool] %s %s\n", name, shown)
+	result, err := sess.CallTool(ctx, name, args)
+	if err != nil {
+		msg := a.redact.Text(err.Error())
+		fmt.Printf("[tool] %s failed: %s\n", name, msg)
+		return "error: " + msg
+	}
+
+	// The model only sees redacted output
+	result = a.redact.Result(result)
+	text := resultText(result)
+	if v := resultValue(result); text == "" && v != nil {
+		data, _ := json.Marshal(v)
+		text = string(data)
+	}
+	status := "returned"
+	if result.IsError {
+		status = "reported an error,"
+		text = "error: " + text
+	}
+	fmt.Printf("[tool] %s %s %d bytes\n", name, status, len(text))
+	if len(text) > maxToolResultChars {
+		// Cut at the start of a rune, not inside one
+		cut := maxToolResultChars
+		for cut > 0 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		text = text[:cut] + "\n[truncated]"
+	}
+	return text
+}
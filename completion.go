@@ -0,0 +1,384 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ThinkInAIXYZ/go-mcp/protocol"
+)
+
+// subcommands are the commands completed after the global flags.
+var subcommands = []string{"audit", "batch", "chat", "completion", "daemon", "inspect", "profile", "run", "sql", "watch"}
+
+// subcommandArgs are the fixed first arguments of some commands.
+var subcommandArgs = map[string][]string{
+	"completion": {"bash", "zsh", "fish"},
+	"daemon":     {"start", "stop", "status", "run"},
+}
+
+// runCompletionCommand implements "completion bash|zsh|fish", printing a
+// script that completes through "__complete".
+func runCompletionCommand(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: completion bash|zsh|fish")
+	}
+	prog := filepath.Base(os.Args[0])
+	fn := "_" + regexp.MustCompile(`[^A-Za-z0-9_]`).ReplaceAllString(prog, "_")
+	var script string
+	switch args[0] {
+	case "bash":
+		script = bashCompletion
+	case "zsh":
+		script = zshCompletion
+	case "fish":
+		script = fishCompletion
+	default:
+		return fmt.Errorf("completion: unknown shell %q", args[0])
+	}
+	script = strings.NewReplacer("PROG", prog, "FUNC", fn).Replace(script)
+	_, err := io.WriteString(os.Stdout, script)
+	return err
+}
+
+// The scripts pass the words up to the cursor to "PROG __complete" and
+// fall back to file names when it prints nothing.
+
+const bashCompletion = `# bash completion for PROG
+FUNC() {
+    local line=${COMP_LINE:0:$COMP_POINT}
+    local -a words
+    read -ra words <<< "$line"
+    [[ $line == *[[:space:]] ]] && words+=("")
+    local cur=${words[${#words[@]}-1]}
+    local IFS=$'\n'
+    COMPREPLY=($("${words[0]}" __complete "${words[@]:1}" 2>/dev/null))
+    # Bash splits the current word at = and :, so drop what it already has
+    local bashcur=${COMP_WORDS[COMP_CWORD]}
+    if [[ $cur != "$bashcur" ]]; then
+        local keep=${cur%"$bashcur"}
+        COMPREPLY=("${COMPREPLY[@]#"$keep"}")
+    fi
+    if [[ ${#COMPREPLY[@]} -eq 1 && ${COMPREPLY[0]} == *= ]]; then
+        compopt -o nospace
+    fi
+}
+complete -o default -F FUNC PROG
+`
+
+const zshCompletion = `#compdef PROG
+FUNC() {
+    local -a cands eq plain
+    cands=("${(@f)$(${words[1]} __complete "${(@)words[2,CURRENT]}" 2>/dev/null)}")
+    cands=(${cands:#})
+    if (( ${#cands} == 0 )); then
+        _files
+        return
+    fi
+    eq=(${(M)cands:#*=})
+    plain=(${cands:#*=})
+    (( ${#plain} )) && compadd -Q -- "${plain[@]}"
+    (( ${#eq} )) && compadd -Q -S '' -- "${eq[@]}"
+}
+compdef FUNC PROG
+`
+
+const fishCompletion = `# fish completion for PROG
+function FUNC
+    set -l tokens (commandline -opc) (commandline -ct)
+    set -l out ($tokens[1] __complete $tokens[2..-1] 2>/dev/null)
+    if test (count $out) -eq 0
+        __fish_complete_path (commandline -ct)
+        return
+    end
+    printf '%s\n' $out
+end
+complete -c PROG -f -a '(FUNC)'
+`
+
+// runComplete implements the hidden "__complete" command: it prints the
+// completions of the last of words, one per line. It never contacts a
+// server; tools and resources come from the catalog cache.
+func runComplete(fs *flag.FlagSet, words []string) {
+	for _, c := range complete(fs, words) {
+		fmt.Println(c)
+	}
+}
+
+// completionState is what the words before the cursor set.
+type completionState struct {
+	flags   map[string]string
+	args    map[string]bool // -arg names already given
+	command string
+	nargs   int // words after the command
+}
+
+func complete(fs *flag.FlagSet, words []string) []string {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	cur := words[len(words)-1]
+	st := completionState{flags: map[string]string{}, args: map[string]bool{}}
+
+	// Read the flags before the cursor; a flag whose value is the current
+	// word is left in pending
+	pending := ""
+	for _, w := range words[:len(words)-1] {
+		switch {
+		case pending != "":
+			st.set(pending, w)
+			pending = ""
+		case st.command != "":
+			st.nargs++
+		case strings.HasPrefix(w, "-") && w != "-":
+			name := strings.TrimLeft(w, "-")
+			if i := strings.IndexByte(name, '='); i >= 0 {
+				st.set(name[:i], name[i+1:])
+				continue
+			}
+			if f := fs.Lookup(name); f != nil && isBoolFlag(f) {
+				st.set(name, "true")
+			} else if f != nil {
+				pending = name
+			}
+		default:
+			st.command = w
+		}
+	}
+
+	if pending != "" {
+		return st.values(fs, pending, cur, "")
+	}
+	switch {
+	case st.command != "":
+		if st.nargs == 0 {
+			return matching(subcommandArgs[st.command], cur)
+		}
+		return nil
+	case strings.HasPrefix(cur, "-"):
+		if i := strings.IndexByte(cur, '='); i >= 0 {
+			return st.values(fs, strings.TrimLeft(cur[:i], "-"), cur[i+1:], cur[:i+1])
+		}
+		var names []string
+		fs.VisitAll(func(f *flag.Flag) {
+			names = append(names, "-"+f.Name)
+		})
+		return matching(names, cur)
+	}
+	return matching(subcommands, cur)
+}
+
+func (st completionState) set(name, value string) {
+	if name == "arg" {
+		if k, _, ok := strings.Cut(value, "="); ok {
+			st.args[k] = true
+		}
+		return
+	}
+	st.flags[name] = value
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// values completes cur as the value of the flag name; prefix is put in
+// front of every candidate.
+func (st completionState) values(fs *flag.FlagSet, name, cur, prefix string) []string {
+	var cands []string
+	switch name {
+	case "server":
+		cands = st.serverNames()
+	case "servers":
+		// Complete the last name of the list
+		done := ""
+		if i := strings.LastIndexByte(cur, ','); i >= 0 {
+			done, cur = cur[:i+1], cur[i+1:]
+		}
+		for _, s := range matching(st.serverNames(), cur) {
+			cands = append(cands, prefix+done+s)
+		}
+		return cands
+	case "list":
+		cands = []string{catalogTools, catalogResources, catalogPrompts}
+	case "format":
+		cands = []string{"table", "csv", "tsv", "jsonl", "parquet"}
+	case "middleware":
+		cands = middlewareNames()
+	case "tool":
+		for _, t := range st.tools(fs) {
+			cands = append(cands, t.Name)
+		}
+	case "resource":
+		for _, r := range st.resources(fs) {
+			cands = append(cands, r.URI)
+		}
+	case "arg":
+		cands = st.argValues(fs, cur)
+	default:
+		// File names and free text
+		return nil
+	}
+	var out []string
+	for _, c := range matching(cands, cur) {
+		out = append(out, prefix+c)
+	}
+	return out
+}
+
+// argValues completes "-arg": argument names of the -tool before the
+// cursor, then enum values once the name is typed.
+func (st completionState) argValues(fs *flag.FlagSet, cur string) []string {
+	name := st.flags["tool"]
+	if name == "" {
+		return nil
+	}
+	var props map[string]schemaProperty
+	for _, t := range st.tools(fs) {
+		if t.Name == name {
+			props = toolProperties(t)
+		}
+	}
+
+	var cands []string
+	if k, _, ok := strings.Cut(cur, "="); ok {
+		prop := props[k]
+		values := prop.Enum
+		if len(values) == 0 && prop.Type == "boolean" {
+			values = []interface{}{true, false}
+		}
+		for _, v := range values {
+			cands = append(cands, k+"="+fmt.Sprint(v))
+		}
+		return cands
+	}
+	for k := range props {
+		if !st.args[k] {
+			cands = append(cands, k+"=")
+		}
+	}
+	sort.Strings(cands)
+	return cands
+}
+
+// schemaProperty is the part of an input schema property completion uses.
+type schemaProperty struct {
+	Type interface{}   `json:"type"`
+	Enum []interface{} `json:"enum"`
+}
+
+func toolProperties(tool *protocol.Tool) map[string]schemaProperty {
+	var t struct {
+		InputSchema struct {
+			Properties map[string]schemaProperty `json:"properties"`
+		} `json:"inputSchema"`
+	}
+	data, _ := json.Marshal(tool)
+	json.Unmarshal(data, &t)
+	return t.InputSchema.Properties
+}
+
+func (st completionState) config() *config {
+	cfg, err := loadConfig(st.flags["config"])
+	if err != nil {
+		return &config{}
+	}
+	return cfg
+}
+
+func (st completionState) serverNames() []string {
+	var names []string
+	for name := range st.config().Servers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// urls returns the servers the command line points at, as -tool and
+// -resource would use them.
+func (st completionState) urls(fs *flag.FlagSet) []string {
+	cfg := st.config()
+	var names []string
+	switch {
+	case st.flags["all"] == "true":
+		names = st.serverNames()
+	case st.flags["servers"] != "":
+		names = strings.Split(st.flags["servers"], ",")
+	case st.flags["server"] != "":
+		names = []string{st.flags["server"]}
+	}
+	var urls []string
+	for _, name := range names {
+		if srv, ok := cfg.Servers[name]; ok {
+			urls = append(urls, srv.URL)
+		}
+	}
+	if len(names) == 0 {
+		url := st.flags["url"]
+		if url == "" {
+			url = fs.Lookup("url").DefValue
+		}
+		urls = append(urls, url)
+	}
+	return urls
+}
+
+// tools returns the cached tools of the servers in use, as the policy
+// shows them.
+func (st completionState) tools(fs *flag.FlagSet) []*protocol.Tool {
+	cfg := st.config()
+	catalogs, err := newCatalogCache(cfg.Catalog)
+	if err != nil {
+		return nil
+	}
+	policy, err := newToolPolicy(cfg.Policy)
+	if err != nil {
+		return nil
+	}
+	seen := map[string]bool{}
+	var out []*protocol.Tool
+	for _, url := range st.urls(fs) {
+		var tools []*protocol.Tool
+		catalogs.peek(url, catalogTools, &tools)
+		for _, t := range policy.apply(tools) {
+			if !seen[t.Name] {
+				seen[t.Name] = true
+				out = append(out, t)
+			}
+		}
+	}
+	return out
+}
+
+func (st completionState) resources(fs *flag.FlagSet) []*protocol.Resource {
+	catalogs, err := newCatalogCache(st.config().Catalog)
+	if err != nil {
+		return nil
+	}
+	var out []*protocol.Resource
+	for _, url := range st.urls(fs) {
+		var resources []*protocol.Resource
+		catalogs.peek(url, catalogResources, &resources)
+		out = append(out, resources...)
+	}
+	return out
+}
+
+// matching returns the candidates starting with prefix.
+func matching(cands []string, prefix string) []string {
+	var out []string
+	for _, c := range cands {
+		if strings.HasPrefix(c, prefix) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
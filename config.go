@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// serverConfig describes a named MCP server. The URL scheme picks the
+// transport: http and https for SSE, ws and wss for WebSocket, unix and
+// tcp for newline-delimited JSON-RPC, and http+unix for SSE over a Unix
+// socket.
+type serverConfig struct {
+	URL string `json:"url"`
+	// Headers are sent with every request or handshake, for example
+	// {"Authorization": "Bearer $MCP_TOKEN"}.
+	Headers map[string]string `json:"headers,omitempty"`
+	// TLS configures certificates for https and wss.
+	TLS tlsConfig `json:"tls"`
+}
+
+// config is the JSON configuration file passed with -config.
+type config struct {
+	// Servers names MCP servers for -server and the daemon.
+	Servers map[string]serverConfig `json:"servers,omitempty"`
+	// Catalog configures the on-disk cache of tool, resource and prompt lists.
+	Catalog catalogConfig `json:"catalog"`
+	// Cache configures reuse of read-only tool results.
+	Cache resultCacheConfig `json:"cache"`
+	// Policy controls which upstream tools are exposed to the user.
+	Policy policyConfig `json:"policy"`
+	// SQLGuard lists tool arguments that may only carry read-only SQL.
+	SQLGuard []sqlGuardRule `json:"sqlGuard,omitempty"`
+	// Audit enables the audit log of tool calls and resource reads.
+	Audit auditConfig `json:"audit"`
+	// Redact hides secrets in logs, printed output and audit records.
+	Redact redactConfig `json:"redact"`
+	// Profile maps the profile command onto the server's tools.
+	Profile profileConfig `json:"profile"`
+	// Chat points the chat command at a chat completions endpoint.
+	Chat chatConfig `json:"chat"`
+	// SQL names the tool the sql command runs statements through.
+	SQL sqlConfig `json:"sql"`
+	// Middleware wraps every server connection, first entry outermost.
+	Middleware []middlewareConfig `json:"middleware,omitempty"`
+}
+
+// loadConfig reads the config file at path. An empty path yields the zero
+// configuration, which exposes every upstream tool unchanged.
+func loadConfig(path string) (*config, error) {
+	cfg := &config{}
+	if path == "" {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("parse config %s: %w", path, err)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/ThinkInAIXYZ/go-mcp/protocol"
+)
+
+// errNotConfirmed is returned when the user declines a tool call.
+var errNotConfirmed = errors.New("tool call not confirmed")
+
+// confirmer asks the user before a destructive tool call is sent.
+type confirmer struct {
+	patterns  []string // upstream names or globs that always need confirmation
+	assumeYes bool     // set by --yes
+	redact    *redactor
+	in        io.Reader
+	reader    *bufio.Reader // buffers in across prompts
+	out       io.Writer
+
+	mu sync.Mutex // one prompt at a time when calls run concurrently
+}
+
+func newConfirmer(patterns []string) *confirmer {
+	return &confirmer{patterns: patterns, in: os.Stdin, reader: bufio.NewReader(os.Stdin), out: os.Stderr}
+}
+
+// readLine reads a line of input. Prompts that share the confirmer's
+// input, like the chat REPL, read through it so that neither loses what
+// the other has buffered.
+func (c *confirmer) readLine() (string, error) {
+	return c.reader.ReadString('\n')
+}
+
+// required reports whether calling tool needs confirmation: either the
+// server marked it destructive, or the policy lists it explicitly. Tools
+// without annotations are only gated by the policy.
+func (c *confirmer) required(tool *protocol.Tool) bool {
+	if matchAny(c.patterns, tool.Name) {
+		return true
+	}
+	a := tool.Annotations
+	if a == nil || a.DestructiveHint == nil {
+		return false
+	}
+	return *a.DestructiveHint && !annotationHint(tool, "readOnlyHint")
+}
+
+// check shows the call and waits for the user to accept it. It refuses
+// when no terminal is attached, so scripts must pass --yes explicitly.
+func (c *confirmer) check(name string, tool *protocol.Tool, args map[string]interface{}) error {
+	if c.assumeYes || !c.required(tool) {
+		return nil
+	}
+	if f, ok := c.in.(*os.File); ok && !isTerminal(f) {
+		return fmt.Errorf("%w: %s needs confirmation, rerun with --yes", errNotConfirmed, name)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	label := name
+	if tool.Name != name {
+		label = fmt.Sprintf("%s (upstream %s)", name, tool.Name)
+	}
+	pretty, err := json.MarshalIndent(c.redact.Args(args), "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode arguments: %w", err)
+	}
+	fmt.Fprintf(c.out, "\nAbout to call tool %s with arguments:\n%s\n", label, pretty)
+	fmt.Fprint(c.out, "Proceed? [y/N]: ")
+
+	answer, _ := c.readLine()
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	}
+	return fmt.Errorf("%w: %s", errNotConfirmed, name)
+}
+
+// isTerminal reports whether f is attached to a character device.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// preferredExtensions overrides mime.ExtensionsByType, which returns
+// several extensions for common types in no useful order.
+var preferredExtensions = map[string]string{
+	"image/png":                ".png",
+	"image/jpeg":               ".jpg",
+	"image/gif":                ".gif",
+	"image/webp":               ".webp",
+	"image/svg+xml":            ".svg",
+	"audio/wav":                ".wav",
+	"audio/x-wav":              ".wav",
+	"audio/mpeg":               ".mp3",
+	"audio/ogg":                ".ogg",
+	"application/pdf":          ".pdf",
+	"application/json":         ".json",
+	"application/octet-stream": ".bin",
+	"text/plain":               ".txt",
+	"text/csv":                 ".csv",
+}
+
+// extensionFor returns a file extension for a MIME type, or ".bin".
+func extensionFor(mimeType string) string {
+	mediaType, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		return ".bin"
+	}
+	if ext, ok := preferredExtensions[mediaType]; ok {
+		return ext
+	}
+	if exts, _ := mime.ExtensionsByType(mediaType); len(exts) > 0 {
+		return exts[0]
+	}
+	return ".bin"
+}
+
+// savedContent describes one content item written to disk.
+type savedContent struct {
+	Index    int
+	Type     string
+	MimeType string
+	Path     string
+	Data     []byte
+}
+
+// binaryContent returns the decoded payload of image, audio and embedded
+// blob items. Text items return ok == false.
+func binaryContent(c contentItem) (data []byte, mimeType string, ok bool, err error) {
+	var encoded string
+	switch {
+	case c.Type == "image" || c.Type == "audio":
+		encoded, mimeType = c.Data, c.MimeType
+	case c.Type == "resource" && c.Resource != nil && c.Resource.Blob != "":
+		encoded, mimeType = c.Resource.Blob, c.Resource.MimeType
+	default:
+		return nil, "", false, nil
+	}
+	data, err = base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, mimeType, true, fmt.Errorf("decode %s content: %w", c.Type, err)
+	}
+	return data, mimeType, true, nil
+}
+
+// saveContent writes every binary content item of r to dir as
+// content-<index><ext>, where index is the item's position in the result.
+func saveContent(dir string, r *toolResult) ([]savedContent, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	var saved []savedContent
+	for i, c := range r.Content {
+		data, mimeType, ok, err := binaryContent(c)
+		if err != nil {
+			return saved, fmt.Errorf("content %d: %w", i, err)
+		}
+		if !ok {
+			continue
+		}
+		path := filepath.Join(dir, fmt.Sprintf("content-%d%s", i, extensionFor(mimeType)))
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			return saved, err
+		}
+		saved = append(saved, savedContent{Index: i, Type: c.Type, MimeType: mimeType, Path: path, Data: data})
+	}
+	return saved, nil
+}
+
+// imageProtocol returns "kitty" or "iterm" when the terminal can show
+// images inline, or "" when it cannot.
+func imageProtocol() string {
+	if !isTerminal(os.Stdout) {
+		return ""
+	}
+	if os.Getenv("KITTY_WINDOW_ID") != "" || os.Getenv("TERM") == "xterm-kitty" {
+		return "kitty"
+	}
+	switch os.Getenv("TERM_PROGRAM") {
+	case "iTerm.app", "WezTerm":
+		return "iterm"
+	}
+	return ""
+}
+
+// previewImage draws an image inline using the given terminal protocol.
+// Kitty only accepts PNG directly, so other formats are skipped there.
+func previewImage(w io.Writer, protocol string, data []byte, mimeType string) bool {
+	if !strings.HasPrefix(mimeType, "image/") {
+		return false
+	}
+	encoded := base64.StdEncoding.EncodeToString(data)
+	switch protocol {
+	case "kitty":
+		if mimeType != "image/png" {
+			return false
+		}
+		// The payload is sent in chunks of at most 4096 bytes
+		for first := true; len(encoded) > 0; first = false {
+			chunk := encoded
+			if len(chunk) > 4096 {
+				chunk = chunk[:4096]
+			}
+			encoded = encoded[len(chunk):]
+			more := 0
+			if len(encoded) > 0 {
+				more = 1
+			}
+			if first {
+				fmt.Fprintf(w, "\x1b_Gf=100,a=T,m=%d;%s\x1b\\", more, chunk)
+			} else {
+				fmt.Fprintf(w, "\x1b_Gm=%d;%s\x1b\\", more, chunk)
+			}
+		}
+		fmt.Fprintln(w)
+		return true
+	case "iterm":
+		fmt.Fprintf(w, "\x1b]1337;File=inline=1;size=%d:%s\a\n", len(data), encoded)
+		return true
+	}
+	return false
+}
+
+// handleBinaryContent saves binary content to dir, when set, and previews
+// images inline when requested and supported.
+func handleBinaryContent(r *toolResult, dir string, preview bool) error {
+	protocol := ""
+	if preview {
+		protocol = imageProtocol()
+	}
+
+	var items []savedContent
+	if dir != "" {
+		saved, err := saveContent(dir, r)
+		if err != nil {
+			return err
+		}
+		for _, item := range saved {
+			fmt.Fprintf(os.Stderr, "Saved %s content %d (%s) to %s\n", item.Type, item.Index, item.MimeType, item.Path)
+		}
+		items = saved
+	} else {
+		for i, c := range r.Content {
+			data, mimeType, ok, err := binaryContent(c)
+			if err != nil {
+				return fmt.Errorf("content %d: %w", i, err)
+			}
+			if ok {
+				items = append(items, savedContent{Index: i, Type: c.Type, MimeType: mimeType, Data: data})
+			}
+		}
+	}
+
+	if protocol != "" {
+		for _, item := range items {
+			previewImage(os.Stdout, protocol, item.Data, item.MimeType)
+		}
+	}
+	return nil
+}
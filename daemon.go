@@ -0,0 +1,744 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/ThinkInAIXYZ/go-mcp/protocol"
+)
+
+// The daemon keeps MCP sessions open between CLI invocations. It speaks
+// JSON lines over a Unix socket: each request names the server URL and an
+// MCP method, and the reply carries the raw MCP result. Policy,
+// confirmation, redaction and auditing stay in the CLI process.
+
+// daemonRequest is one request to the daemon.
+type daemonRequest struct {
+	ID        uint64          `json:"id"`
+	Server    string          `json:"server,omitempty"`
+	Method    string          `json:"method"`
+	Params    json.RawMessage `json:"params,omitempty"`
+	TimeoutMS int64           `json:"timeoutMs,omitempty"`
+}
+
+// daemonResponse answers the request with the same ID.
+type daemonResponse struct {
+	ID     uint64          `json:"id"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// daemonStatus is the result of "daemon/status".
+type daemonStatus struct {
+	PID      int                  `json:"pid"`
+	Started  time.Time            `json:"started"`
+	Idle     string               `json:"idleTimeout"`
+	Sessions []daemonSessionState `json:"sessions"`
+}
+
+type daemonSessionState struct {
+	Server     string    `json:"server"`
+	Connected  bool      `json:"connected"`
+	Since      time.Time `json:"since,omitempty"`
+	LastUsed   time.Time `json:"lastUsed,omitempty"`
+	Requests   int       `json:"requests"`
+	Reconnects int       `json:"reconnects"`
+}
+
+// defaultSocketPath returns the per-user daemon socket path.
+func defaultSocketPath() string {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), fmt.Sprintf("mcp-client-%d", os.Getuid()))
+	} else {
+		dir = filepath.Join(dir, "mcp-client")
+	}
+	return filepath.Join(dir, "daemon.sock")
+}
+
+// daemonConn is an mcpConn that sends requests through the daemon.
+// Requests may be issued concurrently; replies are matched by ID.
+type daemonConn struct {
+	server string
+	conn   net.Conn
+
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	nextID  uint64
+	pending map[uint64]chan daemonResponse
+	err     error // set once the connection fails
+}
+
+// dialDaemon connects to a running daemon. It fails quickly when none is
+// listening, so callers can fall back to a direct connection. It also
+// fails when the socket could belong to another user, who would see
+// every call and could forge the results.
+func dialDaemon(socket, server string) (*daemonConn, error) {
+	if err := checkSocketDir(filepath.Dir(socket)); err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Printf("Not using the daemon: %v", err)
+		}
+		return nil, err
+	}
+	conn, err := net.DialTimeout("unix", socket, 200*time.Millisecond)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkDaemonPeer(conn, socket); err != nil {
+		conn.Close()
+		log.Printf("Not using the daemon: %v", err)
+		return nil, err
+	}
+	c := &daemonConn{server: server, conn: conn, pending: make(map[uint64]chan daemonResponse)}
+	go c.readLoop()
+	return c, nil
+}
+
+func (c *daemonConn) readLoop() {
+	scanner := bufio.NewScanner(c.conn)
+	scanner.Buffer(make([]byte, 64*1024), 256<<20)
+	for scanner.Scan() {
+		var resp daemonResponse
+		if err := json.Unmarshal(scanner.Bytes(), &resp); err != nil {
+			continue
+		}
+		c.mu.Lock()
+		ch := c.pending[resp.ID]
+		delete(c.pending, resp.ID)
+		c.mu.Unlock()
+		if ch != nil {
+			ch <- resp
+		}
+	}
+
+	err := scanner.Err()
+	if err == nil {
+		err = errors.New("daemon closed the connection")
+	}
+	c.mu.Lock()
+	c.err = err
+	for id, ch := range c.pending {
+		ch <- daemonResponse{ID: id, Error: err.Error()}
+		delete(c.pending, id)
+	}
+	c.mu.Unlock()
+}
+
+// call sends one request and decodes the result into out.
+func (c *daemonConn) call(ctx context.Context, method string, params, out interface{}) error {
+	req := daemonRequest{Server: c.server, Method: method}
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+		req.Params = data
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		req.TimeoutMS = time.Until(deadline).Milliseconds()
+	}
+
+	ch := make(chan daemonResponse, 1)
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return c.err
+	}
+	c.nextID++
+	req.ID = c.nextID
+	c.pending[req.ID] = ch
+	c.mu.Unlock()
+
+	line, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	c.writeMu.Lock()
+	_, err = c.conn.Write(append(line, '\n'))
+	c.writeMu.Unlock()
+	if err != nil {
+		return fmt.Errorf("daemon: %w", err)
+	}
+
+	select {
+	case resp := <-ch:
+		if resp.Error != "" {
+			return errors.New(resp.Error)
+		}
+		if out == nil {
+			return nil
+		}
+		return json.Unmarshal(resp.Result, out)
+	case <-ctx.Done():
+		c.mu.Lock()
+		delete(c.pending, req.ID)
+		c.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+func (c *daemonConn) ListTools(ctx context.Context) (*protocol.ListToolsResult, error) {
+	var out protocol.ListToolsResult
+	if err := c.call(ctx, "tools/list", nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *daemonConn) CallTool(ctx context.Context, request *protocol.CallToolRequest) (*protocol.CallToolResult, error) {
+	var out protocol.CallToolResult
+	if err := c.call(ctx, "tools/call", request, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *daemonConn) ReadResource(ctx context.Context, request *protocol.ReadResourceRequest) (*protocol.ReadResourceResult, error) {
+	var out protocol.ReadResourceResult
+	if err := c.call(ctx, "resources/read", request, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *daemonConn) GetPrompt(ctx context.Context, request *protocol.GetPromptRequest) (*protocol.GetPromptResult, error) {
+	var out protocol.GetPromptResult
+	if err := c.call(ctx, "prompts/get", request, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *daemonConn) ListResources(ctx context.Context) (*protocol.ListResourcesResult, error) {
+	var out protocol.ListResourcesResult
+	if err := c.call(ctx, "resources/list", nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *daemonConn) ListPrompts(ctx context.Context) (*protocol.ListPromptsResult, error) {
+	var out protocol.ListPromptsResult
+	if err := c.call(ctx, "prompts/list", nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetServerInfo returns what the server reported when the daemon
+// connected, or the zero value if the daemon cannot reach it.
+func (c *daemonConn) GetServerInfo() protocol.Implementation {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var out protocol.Implementation
+	c.call(ctx, "server/info", nil, &out)
+	return out
+}
+
+// Close closes the socket. The daemon keeps the server session open.
+func (c *daemonConn) Close() error {
+	return c.conn.Close()
+}
+
+// warmSession is a server session held by the daemon.
+type warmSession struct {
+	url      string
+	onNotify notificationHandler
+	options  func(url string) (dialOptions, error)
+
+	mu         sync.Mutex
+	client     *rpcMux // nil until connected or after expiry
+	since      time.Time
+	lastUsed   time.Time // when the last request started or finished
+	inFlight   int
+	requests   int
+	reconnects int
+}
+
+// get returns a connected client, dialing when needed. Each successful
+// get must be paired with a release.
+func (w *warmSession) get() (*rpcMux, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.lastUsed = time.Now()
+	w.requests++
+	if w.client == nil {
+		c, err := w.dial()
+		if err != nil {
+			return nil, err
+		}
+		w.client, w.since = c, time.Now()
+	}
+	w.inFlight++
+	return w.client, nil
+}
+
+// release ends a request started with get.
+func (w *warmSession) release() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.inFlight--
+	w.lastUsed = time.Now()
+}
+
+func (w *warmSession) dial() (*rpcMux, error) {
+	opts, err := w.options(w.url)
+	if err != nil {
+		return nil, err
+	}
+	opts.onNotify = w.onNotify
+	return dialMCP(w.url, opts)
+}
+
+// reconnect replaces broken, unless another request already did.
+func (w *warmSession) reconnect(broken *rpcMux) (*rpcMux, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.client != broken && w.client != nil {
+		return w.client, nil
+	}
+	if broken != nil {
+		broken.Close()
+	}
+	w.client = nil
+	log.Printf("Reconnecting to %s", w.url)
+	c, err := w.dial()
+	if err != nil {
+		return nil, err
+	}
+	w.client, w.since = c, time.Now()
+	w.reconnects++
+	return c, nil
+}
+
+// do runs fn against the session. When fn fails and the server no longer
+// answers a ping, the session is reconnected and, if retry is set, fn is
+// run again once. retry must only be set when running fn twice is safe.
+func (w *warmSession) do(ctx context.Context, retry bool, fn func(*rpcMux) (interface{}, error)) (interface{}, error) {
+	c, err := w.get()
+	if err != nil {
+		return nil, err
+	}
+	defer w.release()
+	result, err := fn(c)
+	if err == nil {
+		return result, nil
+	}
+
+	// A request that ran out of time or was cancelled says nothing about
+	// the session, which other requests may be using
+	if ctx.Err() != nil {
+		return nil, err
+	}
+	pingCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	pingErr := c.Ping(pingCtx)
+	cancel()
+	if pingErr == nil {
+		return nil, err
+	}
+	c, rerr := w.reconnect(c)
+	if rerr != nil {
+		return nil, fmt.Errorf("%v (reconnect failed: %v)", err, rerr)
+	}
+	if !retry {
+		return nil, err
+	}
+	return fn(c)
+}
+
+// expireIfIdle closes the client when no request has run for idle. A
+// session with a request in flight is never closed.
+func (w *warmSession) expireIfIdle(idle time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.client != nil && w.inFlight == 0 && time.Since(w.lastUsed) > idle {
+		log.Printf("Closing idle session to %s", w.url)
+		w.client.Close()
+		w.client = nil
+	}
+}
+
+func (w *warmSession) state() daemonSessionState {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return daemonSessionState{
+		Server:     w.url,
+		Connected:  w.client != nil,
+		Since:      w.since,
+		LastUsed:   w.lastUsed,
+		Requests:   w.requests,
+		Reconnects: w.reconnects,
+	}
+}
+
+// daemon serves warm sessions on a Unix socket.
+type daemon struct {
+	catalogs *catalogCache
+	options  func(url string) (dialOptions, error)
+	idle     time.Duration
+	started  time.Time
+	done     chan struct{}
+	stop     sync.Once
+
+	mu       sync.Mutex
+	sessions map[string]*warmSession
+}
+
+// idempotentTool reports whether the cached catalog of server marks the
+// tool idempotent, so a failed call may be sent again. Unknown tools are
+// not.
+func (d *daemon) idempotentTool(server, name string) bool {
+	var tools []*protocol.Tool
+	if !d.catalogs.peek(server, catalogTools, &tools) {
+		return false
+	}
+	for _, t := range tools {
+		if t != nil && t.Name == name {
+			return annotationHint(t, "idempotentHint")
+		}
+	}
+	return false
+}
+
+func (d *daemon) session(url string) *warmSession {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	w, ok := d.sessions[url]
+	if !ok {
+		// Cached catalogs are dropped when a warm server announces changes
+		w = &warmSession{url: url, onNotify: d.catalogs.listChangedHandler(url, nil), options: d.options}
+		d.sessions[url] = w
+	}
+	return w
+}
+
+// serve accepts connections until the daemon is stopped.
+func (d *daemon) serve(ln net.Listener) {
+	go func() {
+		<-d.done
+		ln.Close()
+	}()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go d.handleConn(conn)
+	}
+}
+
+func (d *daemon) handleConn(conn net.Conn) {
+	defer conn.Close()
+	var writeMu sync.Mutex
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 64*1024), 64<<20)
+	for scanner.Scan() {
+		var req daemonRequest
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			continue
+		}
+		go func() {
+			resp := d.handle(req)
+			line, _ := json.Marshal(resp)
+			writeMu.Lock()
+			conn.Write(append(line, '\n'))
+			writeMu.Unlock()
+			// Stop only once the reply is out, or the process may exit first
+			if req.Method == "daemon/stop" {
+				d.stop.Do(func() { close(d.done) })
+			}
+		}()
+	}
+}
+
+// handle runs one request.
+func (d *daemon) handle(req daemonRequest) daemonResponse {
+	ctx := context.Background()
+	if req.TimeoutMS > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(req.TimeoutMS)*time.Millisecond)
+		defer cancel()
+	}
+
+	var (
+		result interface{}
+		err    error
+	)
+	switch req.Method {
+	case "daemon/status":
+		result = d.status()
+	case "daemon/stop":
+		// handleConn stops the daemon after replying
+	case "tools/list":
+		result, err = d.session(req.Server).do(ctx, true, func(c *rpcMux) (interface{}, error) {
+			return c.ListTools(ctx)
+		})
+	case "tools/call":
+		var p protocol.CallToolRequest
+		if err = json.Unmarshal(req.Params, &p); err == nil {
+			// Calling a tool twice may do its work twice
+			retry := d.idempotentTool(req.Server, p.Name)
+			result, err = d.session(req.Server).do(ctx, retry, func(c *rpcMux) (interface{}, error) {
+				return c.CallTool(ctx, &p)
+			})
+		}
+	case "server/info":
+		result, err = d.session(req.Server).do(ctx, true, func(c *rpcMux) (interface{}, error) {
+			return c.GetServerInfo(), nil
+		})
+	case "resources/list":
+		result, err = d.session(req.Server).do(ctx, true, func(c *rpcMux) (interface{}, error) {
+			return c.ListResources(ctx)
+		})
+	case "prompts/list":
+		result, err = d.session(req.Server).do(ctx, true, func(c *rpcMux) (interface{}, error) {
+			return c.ListPrompts(ctx)
+		})
+	case "resources/read":
+		var p protocol.ReadResourceRequest
+		if err = json.Unmarshal(req.Params, &p); err == nil {
+			result, err = d.session(req.Server).do(ctx, true, func(c *rpcMux) (interface{}, error) {
+				return c.ReadResource(ctx, &p)
+			})
+		}
+	case "prompts/get":
+		var p protocol.GetPromptRequest
+		if err = json.Unmarshal(req.Params, &p); err == nil {
+			result, err = d.session(req.Server).do(ctx, true, func(c *rpcMux) (interface{}, error) {
+				return c.GetPrompt(ctx, &p)
+			})
+		}
+	default:
+		err = fmt.Errorf("unknown method %q", req.Method)
+	}
+
+	resp := daemonResponse{ID: req.ID}
+	if err != nil {
+		resp.Error = err.Error()
+		return resp
+	}
+	if result != nil {
+		if resp.Result, err = json.Marshal(result); err != nil {
+			resp.Error = err.Error()
+		}
+	}
+	return resp
+}
+
+func (d *daemon) status() daemonStatus {
+	d.mu.Lock()
+	sessions := make([]*warmSession, 0, len(d.sessions))
+	for _, w := range d.sessions {
+		sessions = append(sessions, w)
+	}
+	d.mu.Unlock()
+
+	st := daemonStatus{PID: os.Getpid(), Started: d.started, Idle: d.idle.String()}
+	for _, w := range sessions {
+		st.Sessions = append(st.Sessions, w.state())
+	}
+	sort.Slice(st.Sessions, func(i, j int) bool { return st.Sessions[i].Server < st.Sessions[j].Server })
+	return st
+}
+
+// expireLoop closes idle sessions until the daemon stops.
+func (d *daemon) expireLoop() {
+	tick := time.NewTicker(d.idle / 4)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C:
+			d.mu.Lock()
+			for _, w := range d.sessions {
+				w.expireIfIdle(d.idle)
+			}
+			d.mu.Unlock()
+		case <-d.done:
+			return
+		}
+	}
+}
+
+func (d *daemon) closeAll() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, w := range d.sessions {
+		w.mu.Lock()
+		if w.client != nil {
+			w.client.Close()
+			w.client = nil
+		}
+		w.mu.Unlock()
+	}
+}
+
+// runDaemonCommand implements "daemon start|stop|status|run".
+func runDaemonCommand(a *app, configPath string, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: daemon start|stop|status|run [flags]")
+	}
+	fs := flag.NewFlagSet("daemon "+args[0], flag.ExitOnError)
+	idle := fs.Duration("idle", 10*time.Minute, "Close server sessions unused for this long (0 keeps them open)")
+	fs.Parse(args[1:])
+
+	switch args[0] {
+	case "run":
+		return runDaemon(a, *idle)
+	case "start":
+		return startDaemon(a, configPath, *idle)
+	case "stop":
+		c, err := dialDaemon(a.socket, "")
+		if err != nil {
+			return errors.New("daemon is not running")
+		}
+		defer c.Close()
+		if err := c.call(context.Background(), "daemon/stop", nil, nil); err != nil {
+			return err
+		}
+		fmt.Println("Daemon stopped")
+		return nil
+	case "status":
+		c, err := dialDaemon(a.socket, "")
+		if err != nil {
+			fmt.Println("Daemon is not running")
+			return nil
+		}
+		defer c.Close()
+		var st daemonStatus
+		if err := c.call(context.Background(), "daemon/status", nil, &st); err != nil {
+			return err
+		}
+		fmt.Printf("Daemon running, pid %d, up %s, socket %s, idle timeout %s\n",
+			st.PID, time.Since(st.Started).Round(time.Second), a.socket, st.Idle)
+		for _, s := range st.Sessions {
+			state := "idle-closed"
+			if s.Connected {
+				state = "connected " + time.Since(s.Since).Round(time.Second).String()
+			}
+			fmt.Printf("  %s: %s, %d requests, %d reconnects, last used %s ago\n",
+				s.Server, state, s.Requests, s.Reconnects, time.Since(s.LastUsed).Round(time.Second))
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown daemon command %q", args[0])
+}
+
+// runDaemon serves in the foreground until stopped or interrupted.
+func runDaemon(a *app, idle time.Duration) error {
+	if err := prepareSocketDir(filepath.Dir(a.socket)); err != nil {
+		return err
+	}
+	if c, err := dialDaemon(a.socket, ""); err == nil {
+		c.Close()
+		return errors.New("daemon is already running")
+	}
+	os.Remove(a.socket) // left behind by a daemon that did not shut down
+
+	ln, err := net.Listen("unix", a.socket)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(a.socket)
+	if err := os.Chmod(a.socket, 0o600); err != nil {
+		ln.Close()
+		return err
+	}
+
+	d := &daemon{catalogs: a.catalogs, options: a.dialOptions, idle: idle, started: time.Now(), done: make(chan struct{}), sessions: map[string]*warmSession{}}
+	defer d.closeAll()
+
+	// Warm up the configured servers
+	for name, srv := range a.cfg.Servers {
+		w := d.session(srv.URL)
+		go func(name string) {
+			if _, err := w.get(); err != nil {
+				log.Printf("Failed to connect to %s: %v", name, err)
+				return
+			}
+			w.release()
+		}(name)
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-sig:
+			d.stop.Do(func() { close(d.done) })
+		case <-d.done:
+		}
+	}()
+
+	if idle > 0 {
+		go d.expireLoop()
+	}
+	log.Printf("Daemon listening on %s", a.socket)
+	d.serve(ln)
+	log.Printf("Daemon stopped")
+	return nil
+}
+
+// startDaemon runs "daemon run" in the background and waits until it
+// answers on the socket.
+func startDaemon(a *app, configPath string, idle time.Duration) error {
+	if c, err := dialDaemon(a.socket, ""); err == nil {
+		c.Close()
+		fmt.Println("Daemon is already running")
+		return nil
+	}
+	if err := prepareSocketDir(filepath.Dir(a.socket)); err != nil {
+		return err
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	args := []string{"-socket", a.socket}
+	if configPath != "" {
+		if configPath, err = filepath.Abs(configPath); err != nil {
+			return err
+		}
+		args = append(args, "-config", configPath)
+	}
+	args = append(args, "daemon", "run", "-idle", idle.String())
+
+	logPath := filepath.Join(filepath.Dir(a.socket), "daemon.log")
+	logFile, err := os.OpenFile(logPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	cmd := exec.Command(exe, args...)
+	cmd.Stdout, cmd.Stderr = logFile, logFile
+	cmd.SysProcAttr = detachedProcAttr()
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	cmd.Process.Release()
+
+	for i := 0; i < 50; i++ {
+		time.Sleep(100 * time.Millisecond)
+		if c, err := dialDaemon(a.socket, ""); err == nil {
+			c.Close()
+			fmt.Printf("Daemon started, socket %s, log %s\n", a.socket, logPath)
+			return nil
+		}
+	}
+	return fmt.Errorf("daemon did not start; see %s", logPath)
+}
@@ -0,0 +1,21 @@
+//go:build windows || plan9
+
+package main
+
+import (
+	"net"
+	"os"
+	"syscall"
+)
+
+func detachedProcAttr() *syscall.SysProcAttr {
+	return nil
+}
+
+func prepareSocketDir(dir string) error {
+	return os.MkdirAll(dir, 0o700)
+}
+
+func checkSocketDir(dir string) error { return nil }
+
+func checkDaemonPeer(conn net.Conn, socket string) error { return nil }
@@ -0,0 +1,25 @@
+//go:build !linux && !windows && !plan9
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+)
+
+// checkDaemonPeer requires the daemon socket to be owned by the current
+// user. Without SO_PEERCRED the owner of the socket file stands in for
+// the peer; the checked directory keeps others from replacing it.
+func checkDaemonPeer(conn net.Conn, socket string) error {
+	fi, err := os.Lstat(socket)
+	if err != nil {
+		return err
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok || int(st.Uid) != os.Getuid() {
+		return fmt.Errorf("daemon socket %s is not owned by the current user", socket)
+	}
+	return nil
+}
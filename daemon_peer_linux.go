@@ -0,0 +1,38 @@
+//go:build linux
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+)
+
+// checkDaemonPeer requires the process serving the daemon socket to run
+// as the current user.
+func checkDaemonPeer(conn net.Conn, socket string) error {
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		return errors.New("daemon connection is not a Unix socket")
+	}
+	raw, err := uc.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var cred *syscall.Ucred
+	var credErr error
+	if err := raw.Control(func(fd uintptr) {
+		cred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	}); err != nil {
+		return err
+	}
+	if credErr != nil {
+		return fmt.Errorf("daemon socket %s: %w", socket, credErr)
+	}
+	if int(cred.Uid) != os.Getuid() {
+		return fmt.Errorf("daemon socket %s is served by uid %d", socket, cred.Uid)
+	}
+	return nil
+}
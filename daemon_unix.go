@@ -0,0 +1,45 @@
+//go:build !windows && !plan9
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// detachedProcAttr starts the daemon in its own session so it survives
+// the terminal that started it.
+func detachedProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setsid: true}
+}
+
+// prepareSocketDir creates the directory holding the daemon socket and
+// checks that nobody else can reach into it.
+func prepareSocketDir(dir string) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	return checkSocketDir(dir)
+}
+
+// checkSocketDir requires dir to be a real directory owned by the current
+// user with mode 0700: a shared /tmp lets another user create the
+// directory first or plant a symlink there.
+func checkSocketDir(dir string) error {
+	fi, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	if fi.Mode()&os.ModeSymlink != 0 || !fi.IsDir() {
+		return fmt.Errorf("socket directory %s is not a directory", dir)
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok || int(st.Uid) != os.Getuid() {
+		return fmt.Errorf("socket directory %s is not owned by the current user", dir)
+	}
+	if fi.Mode().Perm() != 0o700 {
+		return fmt.Errorf("socket directory %s has mode %#o, want 0700", dir, fi.Mode().Perm())
+	}
+	return nil
+}
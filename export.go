@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// rowWriter streams table rows to one export format. WriteHeader is called
+// once, WriteRows any number of times, then Close.
+type rowWriter interface {
+	WriteHeader(cols []column) error
+	WriteRows(rows [][]interface{}) error
+	Close() error
+}
+
+// exportFormats lists the formats accepted by -format.
+var exportFormats = []string{"table", "csv", "tsv", "jsonl", "parquet"}
+
+// formatFromPath guesses the export format from a file extension.
+func formatFromPath(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return "csv"
+	case ".tsv", ".tab":
+		return "tsv"
+	case ".jsonl", ".ndjson":
+		return "jsonl"
+	case ".parquet":
+		return "parquet"
+	}
+	return "table"
+}
+
+// newRowWriter returns a writer for format. Parquet needs a file, not just
+// a stream, so it is opened by the caller through createRowWriter.
+func newRowWriter(format string, w io.Writer) (rowWriter, error) {
+	switch format {
+	case "table":
+		return &tableWriter{w: w}, nil
+	case "csv":
+		return &csvWriter{w: csv.NewWriter(w)}, nil
+	case "tsv":
+		cw := csv.NewWriter(w)
+		cw.Comma = '\t'
+		return &csvWriter{w: cw}, nil
+	case "jsonl":
+		return &jsonlWriter{w: bufio.NewWriter(w)}, nil
+	case "parquet":
+		return newParquetWriter(w), nil
+	}
+	return nil, fmt.Errorf("unknown format %q (want one of %s)", format, strings.Join(exportFormats, ", "))
+}
+
+// createRowWriter opens path ("-" for stdout) and returns a writer for
+// format, or for the format implied by the extension when format is "".
+// Closing the writer closes the file.
+func createRowWriter(path, format string) (rowWriter, error) {
+	if format == "" {
+		format = formatFromPath(path)
+	}
+	if path == "-" {
+		return newRowWriter(format, os.Stdout)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	rw, err := newRowWriter(format, f)
+	if err != nil {
+		f.Close()
+		os.Remove(path)
+		return nil, err
+	}
+	return &fileRowWriter{rowWriter: rw, f: f}, nil
+}
+
+// exportTable writes a complete table to path.
+func exportTable(path, format string, t *table) error {
+	rw, err := createRowWriter(path, format)
+	if err != nil {
+		return err
+	}
+	if err := rw.WriteHeader(t.Columns); err != nil {
+		rw.Close()
+		return err
+	}
+	if err := rw.WriteRows(t.Rows); err != nil {
+		rw.Close()
+		return err
+	}
+	return rw.Close()
+}
+
+// fileRowWriter closes the underlying file after the format writer.
+type fileRowWriter struct {
+	rowWriter
+	f *os.File
+}
+
+func (w *fileRowWriter) Close() error {
+	err := w.rowWriter.Close()
+	if cerr := w.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// tableWriter buffers rows and renders them aligned on Close, since column
+// widths depend on every row.
+type tableWriter struct {
+	w io.Writer
+	t table
+}
+
+func (tw *tableWriter) WriteHeader(cols []column) error {
+	tw.t.Columns = cols
+	return nil
+}
+
+func (tw *tableWriter) WriteRows(rows [][]interface{}) error {
+	tw.t.Rows = append(tw.t.Rows, rows...)
+	return nil
+}
+
+func (tw *tableWriter) Close() error {
+	return renderTable(tw.w, &tw.t)
+}
+
+// csvWriter writes CSV or TSV. Nulls are written as empty fields.
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func (cw *csvWriter) WriteHeader(cols []column) error {
+	names := make([]string, len(cols))
+	for i, c := range cols {
+		names[i] = c.Name
+	}
+	return cw.w.Write(names)
+}
+
+func (cw *csvWriter) WriteRows(rows [][]interface{}) error {
+	record := []string{}
+	for _, row := range rows {
+		record = record[:0]
+		for _, v := range row {
+			record = append(record, formatCell(v))
+		}
+		if err := cw.w.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+func (cw *csvWriter) Close() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+// jsonlWriter writes one JSON object per row, keeping column order.
+type jsonlWriter struct {
+	w    *bufio.Writer
+	keys [][]byte
+}
+
+func (jw *jsonlWriter) WriteHeader(cols []column) error {
+	for _, c := range cols {
+		key, err := json.Marshal(c.Name)
+		if err != nil {
+			return err
+		}
+		jw.keys = append(jw.keys, key)
+	}
+	return nil
+}
+
+func (jw *jsonlWriter) WriteRows(rows [][]interface{}) error {
+	for _, row := range rows {
+		jw.w.WriteByte('{')
+		for i, v := range row {
+			if i > 0 {
+				jw.w.WriteByte(',')
+			}
+			value, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+			jw.w.Write(jw.keys[i])
+			jw.w.WriteByte(':')
+			jw.w.Write(value)
+		}
+		if _, err := jw.w.WriteString("}\n"); err != nil {
+			return err
+		}
+	}
+	return jw.w.Flush()
+}
+
+func (jw *jsonlWriter) Close() error {
+	return jw.w.Flush()
+}
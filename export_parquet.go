@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// parquetWriter writes rows to a Parquet file. Every column is optional
+// so nulls survive. A Parquet schema cannot change once rows are written,
+// and later pages of a streamed result can disagree with the kinds
+// inferred from the first, so rows are held until Close and each column
+// gets the narrowest kind that fits all of them: int64, then double, then
+// string.
+type parquetWriter struct {
+	out  io.Writer
+	cols []column
+	rows [][]interface{}
+}
+
+func newParquetWriter(w io.Writer) *parquetWriter {
+	return &parquetWriter{out: w}
+}
+
+func (pw *parquetWriter) WriteHeader(cols []column) error {
+	seen := make(map[string]bool)
+	for _, c := range cols {
+		if seen[c.Name] {
+			return fmt.Errorf("parquet: duplicate column %q", c.Name)
+		}
+		seen[c.Name] = true
+	}
+	pw.cols = append([]column(nil), cols...)
+	return nil
+}
+
+func (pw *parquetWriter) WriteRows(rows [][]interface{}) error {
+	for _, row := range rows {
+		for i, v := range row {
+			pw.cols[i].Kind = widenKind(pw.cols[i].Kind, valueKind(v))
+		}
+	}
+	pw.rows = append(pw.rows, rows...)
+	return nil
+}
+
+// valueKind is cellKind for a cell already converted by inferTypes.
+func valueKind(v interface{}) columnKind {
+	switch v.(type) {
+	case nil:
+		return kindNull
+	case int64:
+		return kindInt
+	case float64:
+		return kindFloat
+	case bool:
+		return kindBool
+	}
+	return kindString
+}
+
+func parquetNode(kind columnKind) parquet.Node {
+	switch kind {
+	case kindInt:
+		return parquet.Int(64)
+	case kindFloat:
+		return parquet.Leaf(parquet.DoubleType)
+	case kindBool:
+		return parquet.Leaf(parquet.BooleanType)
+	}
+	return parquet.String()
+}
+
+// coerceCell converts v to kind, which WriteRows widened to fit it.
+func coerceCell(v interface{}, kind columnKind) interface{} {
+	switch kind {
+	case kindFloat:
+		if n, ok := v.(int64); ok {
+			return float64(n)
+		}
+		return v
+	case kindInt, kindBool:
+		return v
+	}
+	return formatCell(v)
+}
+
+// Close writes the held rows and finishes the file.
+func (pw *parquetWriter) Close() error {
+	if pw.cols == nil {
+		return nil
+	}
+	group := parquet.Group{}
+	for _, c := range pw.cols {
+		group[c.Name] = parquet.Optional(parquetNode(c.Kind))
+	}
+	schema := parquet.NewSchema("row", group)
+
+	// The schema sorts columns by name
+	position := make(map[string]int)
+	for i, path := range schema.Columns() {
+		position[path[0]] = i
+	}
+	w := parquet.NewWriter(pw.out, schema)
+	const batchSize = 1024
+	for len(pw.rows) > 0 {
+		n := len(pw.rows)
+		if n > batchSize {
+			n = batchSize
+		}
+		batch := make([]parquet.Row, 0, n)
+		for _, row := range pw.rows[:n] {
+			out := make(parquet.Row, len(row))
+			for i, v := range row {
+				c := pw.cols[i]
+				col := position[c.Name]
+				if v == nil {
+					out[col] = parquet.NullValue().Level(0, 0, col)
+				} else {
+					out[col] = parquet.ValueOf(coerceCell(v, c.Kind)).Level(0, 1, col)
+				}
+			}
+			batch = append(batch, out)
+		}
+		if _, err := w.WriteRows(batch); err != nil {
+			return err
+		}
+		pw.rows = pw.rows[n:]
+	}
+	return w.Close()
+}
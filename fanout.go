@@ -0,0 +1,425 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// fanTarget is one server a fanned-out command runs against.
+type fanTarget struct {
+	Name string
+	URL  string
+}
+
+// fanOutTargets resolves -servers, a comma-separated list of configured
+// server names, or -all, which selects every configured server.
+func fanOutTargets(cfg *config, servers string, all bool) ([]fanTarget, error) {
+	var names []string
+	if all {
+		for name := range cfg.Servers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		if len(names) == 0 {
+			return nil, fmt.Errorf("-all: no servers in the config file")
+		}
+	} else {
+		for _, name := range strings.Split(servers, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+
+	targets := make([]fanTarget, 0, len(names))
+	for _, name := range names {
+		srv, ok := cfg.Servers[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown server %q", name)
+		}
+		targets = append(targets, fanTarget{Name: name, URL: srv.URL})
+	}
+	return targets, nil
+}
+
+// fanValue is what the comparison view compares: a catalog of entry name
+// to definition, or the lines of a result.
+type fanValue struct {
+	Catalog map[string]string
+	Lines   []string
+}
+
+// fanResult is the outcome of a command on one server.
+type fanResult struct {
+	fanTarget
+	Output []byte
+	Value  fanValue
+	Err    error
+}
+
+// runFanOut runs fn against every target in parallel, then prints each
+// server's output in target order, followed by a comparison when compare
+// is set. It fails if any server failed.
+func runFanOut(a *app, targets []fanTarget, compare bool, fn func(ctx context.Context, sess *session, w io.Writer) (fanValue, error)) error {
+	results := make([]fanResult, len(targets))
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func(i int, target fanTarget) {
+			defer wg.Done()
+			r := &results[i]
+			r.fanTarget = target
+
+			sess, err := a.connect(target.URL)
+			if err != nil {
+				r.Err = fmt.Errorf("connect: %w", err)
+				return
+			}
+			defer sess.Close()
+			var buf bytes.Buffer
+			r.Value, r.Err = fn(context.Background(), sess, &buf)
+			r.Output = buf.Bytes()
+		}(i, target)
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, r := range results {
+		fmt.Printf("\n=== %s (%s)\n", r.Name, r.URL)
+		os.Stdout.Write(r.Output)
+		if r.Err != nil {
+			failed++
+			fmt.Printf("Error: %s\n", a.redact.Text(r.Err.Error()))
+		}
+	}
+
+	if compare {
+		printComparison(os.Stdout, results)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d servers failed", failed, len(results))
+	}
+	return nil
+}
+
+// printList writes the tools, resources or prompts of sess to w and
+// returns them as a catalog for comparison.
+func printList(ctx context.Context, sess *session, kind string, w io.Writer) (fanValue, error) {
+	// Set up a custom logger without timestamps
+	logger := log.New(w, "", 0)
+	catalog := map[string]string{}
+	define := func(name string, v interface{}) {
+		data, _ := json.Marshal(v)
+		catalog[name] = string(data)
+	}
+
+	switch kind {
+	case "resources":
+		resources, err := sess.ListResources(ctx)
+		if err != nil {
+			return fanValue{}, err
+		}
+		for _, r := range resources {
+			logger.Printf("URI: %s Name: %s Description: %s\n", r.URI, r.Name, r.Description)
+			define(r.URI, r)
+		}
+	case "prompts":
+		prompts, err := sess.ListPrompts(ctx)
+		if err != nil {
+			return fanValue{}, err
+		}
+		for _, p := range prompts {
+			logger.Printf("Name: %s Description: %s\n", p.Name, p.Description)
+			define(p.Name, p)
+		}
+	case "tools":
+		tools, err := sess.ListTools(ctx)
+		if err != nil {
+			return fanValue{}, err
+		}
+		for _, tool := range tools {
+			logger.Printf("Name: %s Description: %s\n", tool.Name, tool.Description)
+			define(tool.Name, tool)
+		}
+	default:
+		return fanValue{}, fmt.Errorf("unknown -list value %q", kind)
+	}
+	return fanValue{Catalog: catalog}, nil
+}
+
+// resultLines renders a tool result for line-by-line comparison.
+func resultLines(r *toolResult) []string {
+	v := resultValue(r)
+	text, ok := v.(string)
+	if !ok {
+		data, _ := json.MarshalIndent(v, "", "  ")
+		text = string(data)
+	}
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	if r.IsError {
+		lines = append([]string{"(tool reported an error)"}, lines...)
+	}
+	return lines
+}
+
+// resourceLines renders resource contents for line-by-line comparison.
+func resourceLines(contents []resourceContent) []string {
+	var lines []string
+	for _, c := range contents {
+		if c.Blob != "" {
+			lines = append(lines, fmt.Sprintf("(%s blob, %d bytes base64)", c.MimeType, len(c.Blob)))
+			continue
+		}
+		lines = append(lines, strings.Split(strings.TrimRight(c.Text, "\n"), "\n")...)
+	}
+	return lines
+}
+
+// printComparison compares the servers that succeeded against the first
+// of them.
+func printComparison(w io.Writer, results []fanResult) {
+	var ok []fanResult
+	for _, r := range results {
+		if r.Err == nil {
+			ok = append(ok, r)
+		}
+	}
+	fmt.Fprintf(w, "\n=== Comparison\n")
+	if len(ok) < 2 {
+		fmt.Fprintln(w, "Fewer than two servers succeeded; nothing to compare")
+		return
+	}
+	if ok[0].Value.Catalog != nil {
+		compareCatalogs(w, ok)
+	} else {
+		compareLines(w, ok)
+	}
+}
+
+// compareCatalogs shows a row for every entry that is missing from or
+// defined differently on some server.
+func compareCatalogs(w io.Writer, results []fanResult) {
+	names := map[string]bool{}
+	for _, r := range results {
+		for name := range r.Value.Catalog {
+			names[name] = true
+		}
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	t := &table{Columns: []column{{Name: "NAME", Kind: kindString}}}
+	for _, r := range results {
+		t.Columns = append(t.Columns, column{Name: r.Name, Kind: kindString})
+	}
+	same := 0
+	for _, name := range sorted {
+		// The first server that has the entry is the reference
+		var ref string
+		for _, r := range results {
+			if def, ok := r.Value.Catalog[name]; ok {
+				ref = def
+				break
+			}
+		}
+		row := []interface{}{name}
+		differs := false
+		for _, r := range results {
+			def, ok := r.Value.Catalog[name]
+			switch {
+			case !ok:
+				row = append(row, "missing")
+				differs = true
+			case def != ref:
+				row = append(row, "differs")
+				differs = true
+			default:
+				row = append(row, "same")
+			}
+		}
+		if differs {
+			t.Rows = append(t.Rows, row)
+		} else {
+			same++
+		}
+	}
+
+	if len(t.Rows) > 0 {
+		renderTable(w, t)
+	}
+	fmt.Fprintf(w, "%d of %d entries identical on all %d servers\n", same, len(sorted), len(results))
+}
+
+// compareLines diffs each server's result against the first server's.
+func compareLines(w io.Writer, results []fanResult) {
+	base := results[0]
+	f, isFile := w.(*os.File)
+	color := isFile && isTerminal(f)
+	identical := []string{base.Name}
+	for _, r := range results[1:] {
+		diff := diffLines(base.Value.Lines, r.Value.Lines)
+		if diff == nil {
+			identical = append(identical, r.Name)
+			continue
+		}
+		fmt.Fprintf(w, "--- %s\n+++ %s\n", base.Name, r.Name)
+		for _, line := range diff {
+			switch {
+			case color && strings.HasPrefix(line, "-"):
+				fmt.Fprintf(w, "\x1b[31m%s\x1b[0m\n", line)
+			case color && strings.HasPrefix(line, "+"):
+				fmt.Fprintf(w, "\x1b[32m%s\x1b[0m\n", line)
+			default:
+				fmt.Fprintln(w, line)
+			}
+		}
+	}
+	if len(identical) == len(results) {
+		fmt.Fprintf(w, "Results identical on all %d servers\n", len(results))
+	} else if len(identical) > 1 {
+		fmt.Fprintf(w, "Identical results: %s\n", strings.Join(identical, ", "))
+	}
+}
+
+// Bounds on the lines diffLines aligns once the common start and end are
+// trimmed, and on the edits it looks for. The alignment needs time
+// proportional to lines times edits and memory to the square of edits.
+const (
+	maxDiffLines = 5000
+	maxDiffEdits = 500
+)
+
+// diffLines returns the changed lines between a and b, prefixed with "-"
+// or "+" and preceded by "@@ line N" markers, or nil if they are equal.
+func diffLines(a, b []string) []string {
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	if pre == len(a) && pre == len(b) {
+		return nil
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+	a, b = a[pre:len(a)-suf], b[pre:len(b)-suf]
+
+	tooLong := fmt.Sprintf("@@ results differ (%d and %d lines, too many changes to align)", len(a)+pre+suf, len(b)+pre+suf)
+	if len(a) > maxDiffLines || len(b) > maxDiffLines {
+		return []string{tooLong}
+	}
+	ops, ok := editScript(a, b, maxDiffEdits)
+	if !ok {
+		return []string{tooLong}
+	}
+
+	var out []string
+	inHunk := false
+	i, j := 0, 0
+	for _, op := range ops {
+		if op == '=' {
+			i++
+			j++
+			inHunk = false
+			continue
+		}
+		if !inHunk {
+			out = append(out, fmt.Sprintf("@@ line %d", pre+i+1))
+		}
+		if op == '-' {
+			out = append(out, "-"+a[i])
+			i++
+		} else {
+			out = append(out, "+"+b[j])
+			j++
+		}
+		inHunk = true
+	}
+	return out
+}
+
+// editScript finds a shortest edit script from a to b with Myers'
+// algorithm: a sequence of '=' (keep), '-' (delete from a) and '+' (insert
+// from b). It gives up when more than maxEdits edits are needed.
+func editScript(a, b []string, maxEdits int) ([]byte, bool) {
+	n, m := len(a), len(b)
+	limit := n + m
+	if limit > maxEdits {
+		limit = maxEdits
+	}
+	// v[offset+k] is the furthest x reached on diagonal k = x - y
+	offset := limit + 1
+	v := make([]int, 2*limit+3)
+	var trace [][]int // v before each step, for walking back
+	for d := 0; d <= limit; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(trace, n, m), true
+			}
+		}
+	}
+	return nil, false
+}
+
+// backtrack walks the trace of editScript back from (n, m) and returns
+// the edits in order. trace[d] holds diagonals -d-1 to d+1.
+func backtrack(trace [][]int, n, m int) []byte {
+	var ops []byte
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		prev := trace[d]
+		at := func(k int) int { return prev[k+d+1] }
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			ops = append(ops, '=')
+			x--
+			y--
+		}
+		if x == prevX {
+			ops = append(ops, '+')
+		} else {
+			ops = append(ops, '-')
+		}
+		x, y = prevX, prevY
+	}
+	for x > 0 && y > 0 {
+		ops = append(ops, '=')
+		x--
+		y--
+	}
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
@@ -3,45 +3,258 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
+	"io"
 	"log"
 	"os"
-
-	"github.com/ThinkInAIXYZ/go-mcp/client"
-	"github.com/ThinkInAIXYZ/go-mcp/transport"
+	"strings"
 )
 
+// argList collects repeated -arg key=value flags.
+type argList map[string]interface{}
+
+func (a argList) String() string { return fmt.Sprint(map[string]interface{}(a)) }
+
+func (a argList) Set(s string) error {
+	key, value, ok := strings.Cut(s, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("expected key=value, got %q", s)
+	}
+	a[key] = value
+	return nil
+}
+
 func main() {
 	// Define command-line flag for the MCP URL
-	var mcpURL string
+	var mcpURL, serverName, configPath, toolName, resourceURI, outPath, outFormat, saveDir, socket string
+	var listKind, serverList string
+	var assumeYes, noRedact, preview, noDaemon, refresh, noCache, verbose, allServers, compare bool
+	toolArgs := argList{}
+	headers := headerList{}
+	var middlewareFlags middlewareList
+	var tlsFlags tlsConfig
 	flag.StringVar(&mcpURL, "url", "https://mcp-td1.swormlab.com/sse", "MCP server URL")
+	flag.StringVar(&serverName, "server", "", "Name of a server from the config file to connect to instead of -url")
+	flag.StringVar(&serverList, "servers", "", "Comma-separated config server names to run the list or call against in parallel")
+	flag.BoolVar(&allServers, "all", false, "Run the list or call against every server in the config file")
+	flag.BoolVar(&compare, "compare", false, "With -servers or -all, show where catalogs or results differ")
+	flag.StringVar(&configPath, "config", "", "Path to JSON config file")
+	flag.StringVar(&socket, "socket", defaultSocketPath(), "Daemon socket path")
+	flag.BoolVar(&noDaemon, "no-daemon", false, "Connect directly even when a daemon is running")
+	flag.Var(headers, "header", "HTTP header as \"Name: value\" for the server connection. Can be used multiple times.")
+	flag.StringVar(&tlsFlags.CAFile, "ca-cert", "", "PEM file of extra CA certificates to trust for https and wss servers")
+	flag.BoolVar(&tlsFlags.InsecureSkipVerify, "insecure", false, "Skip TLS certificate verification")
+	flag.Var(&middlewareFlags, "middleware", "Wrap the connection in a middleware, as name or name:key=value,...: trace, record, retry or faults. Can be used multiple times.")
+	flag.StringVar(&toolName, "tool", "", "Name of the tool to call directly")
+	flag.Var(toolArgs, "arg", "Tool argument as key=value. Can be used multiple times.")
+	flag.BoolVar(&assumeYes, "yes", false, "Call destructive tools without asking for confirmation")
+	flag.StringVar(&resourceURI, "resource", "", "URI of a resource to read")
+	flag.StringVar(&outPath, "out", "", "Write row data from the tool result to this file (- for stdout)")
+	flag.StringVar(&outFormat, "format", "", "Output format for -out: table, csv, tsv, jsonl or parquet (default from extension)")
+	flag.StringVar(&saveDir, "save-dir", "", "Write image, audio and blob content from the tool result to this directory")
+	flag.BoolVar(&preview, "preview", false, "Show images inline on kitty and iTerm2 compatible terminals")
+	flag.StringVar(&listKind, "list", "tools", "What to list when no tool or resource is given: tools, resources or prompts")
+	flag.BoolVar(&refresh, "refresh", false, "Fetch tool, resource and prompt lists from the server instead of the cache")
+	flag.BoolVar(&noCache, "no-cache", false, "Call tools even when a cached result is available")
+	flag.BoolVar(&verbose, "v", false, "Verbose output, such as result cache hits and misses")
+	flag.BoolVar(&noRedact, "no-redact", false, "Show secrets in logs and output (audit records stay redacted)")
+
+	// Shell completion calls back with the words typed so far
+	if len(os.Args) > 1 && os.Args[1] == "__complete" {
+		runComplete(flag.CommandLine, os.Args[2:])
+		return
+	}
 	flag.Parse()
 
-	// Log which URL we're connecting to
-	log.Printf("Connecting to MCP server: %s", mcpURL)
+	// Commands that work offline
+	switch flag.Arg(0) {
+	case "audit":
+		if err := runAuditCommand(flag.Args()[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	case "completion":
+		if err := runCompletionCommand(flag.Args()[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
-	// Create SSE transport client
-	transportClient, err := transport.NewSSEClientTransport(mcpURL)
+	cfg, err := loadConfig(configPath)
 	if err != nil {
-		log.Fatalf("Failed to create transport client: %v", err)
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	if serverName != "" {
+		srv, ok := cfg.Servers[serverName]
+		if !ok {
+			log.Fatalf("Unknown server %q", serverName)
+		}
+		mcpURL = srv.URL
+	}
+
+	// Hide configured secrets from everything we log or print
+	redact, err := newRedactor(cfg.Redact)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	if noRedact {
+		log.Printf("Warning: redaction disabled by --no-redact")
+		redact.disabled = true
 	}
+	log.SetOutput(redactingWriter{w: os.Stderr, r: redact})
 
-	// Initialize MCP client
-	mcpClient, err := client.NewClient(transportClient)
+	// Set up the policy, confirmation and audit state shared by all sessions
+	a, err := newApp(cfg, redact)
 	if err != nil {
-		log.Fatalf("Failed to create MCP client: %v", err)
+		log.Fatalf("Failed to set up client: %v", err)
+	}
+	defer a.Close()
+	a.confirm.assumeYes = assumeYes
+	a.socket, a.noDaemon = socket, noDaemon
+	a.headers, a.tls = headers, tlsFlags
+	var flagMiddleware []middlewareConfig
+	for _, spec := range middlewareFlags {
+		c, err := parseMiddlewareFlag(spec)
+		if err != nil {
+			log.Fatalf("Invalid -middleware: %v", err)
+		}
+		flagMiddleware = append(flagMiddleware, c)
+	}
+	if err := a.addMiddleware(flagMiddleware); err != nil {
+		log.Fatalf("Invalid -middleware: %v", err)
+	}
+	if len(headers) > 0 || tlsFlags != (tlsConfig{}) || len(flagMiddleware) > 0 {
+		// The daemon only knows the transport settings of the config file
+		a.noDaemon = true
+	}
+	a.catalogs.refresh = refresh
+	a.verbose = verbose
+	if noCache {
+		a.results = nil
+	}
+
+	// runOnce lists or calls what the flags ask for, for fan-out and watch
+	runOnce := func(ctx context.Context, sess *session, w io.Writer) (fanValue, error) {
+		switch {
+		case resourceURI != "":
+			contents, err := sess.ReadResource(ctx, resourceURI)
+			if err != nil {
+				return fanValue{}, err
+			}
+			contents = redact.Resources(contents)
+			printResourceContents(w, resourceURI, contents)
+			return fanValue{Lines: resourceLines(contents)}, nil
+		case toolName != "":
+			result, err := sess.CallTool(ctx, toolName, toolArgs)
+			if err != nil {
+				return fanValue{}, err
+			}
+			result = redact.Result(result)
+			printToolResult(w, toolName, result)
+			return fanValue{Lines: resultLines(result)}, nil
+		default:
+			return printList(ctx, sess, listKind, w)
+		}
+	}
+
+	// Commands that manage their own sessions
+	if cmd := flag.Arg(0); cmd != "" {
+		var err error
+		switch cmd {
+		case "batch":
+			err = runBatch(a, mcpURL, flag.Args()[1:])
+		case "run":
+			err = runWorkflowCommand(a, mcpURL, flag.Args()[1:])
+		case "profile":
+			err = runProfileCommand(a, mcpURL, flag.Args()[1:])
+		case "sql":
+			err = runSQLCommand(a, mcpURL, flag.Args()[1:])
+		case "daemon":
+			err = runDaemonCommand(a, configPath, flag.Args()[1:])
+		case "chat":
+			err = runChatCommand(a, mcpURL, flag.Args()[1:])
+		case "watch":
+			target := watchTarget{tool: toolName, resource: resourceURI, list: listKind, run: runOnce}
+			err = runWatchCommand(a, mcpURL, flag.Args()[1:], target)
+		case "inspect":
+			err = runInspectCommand(a, mcpURL, flag.Args()[1:])
+		default:
+			err = fmt.Errorf("unknown command %q", cmd)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// Run the list or call against several servers at once
+	if serverList != "" || allServers {
+		if outPath != "" || outFormat != "" || saveDir != "" || preview {
+			log.Fatalf("-out, -format, -save-dir and -preview cannot be combined with -servers or -all")
+		}
+		targets, err := fanOutTargets(cfg, serverList, allServers)
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = runFanOut(a, targets, compare, runOnce)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
-	defer mcpClient.Close()
 
-	// Get available tools
-	tools, err := mcpClient.ListTools(context.Background())
+	sess, err := a.connect(mcpURL)
 	if err != nil {
-		log.Fatalf("Failed to list tools: %v", err)
+		log.Fatalf("Failed to connect: %v", err)
 	}
+	defer sess.Close()
 
-	// Set up a custom logger without timestamps
-	logger := log.New(os.Stdout, "", 0)
+	// Read a single resource if one was requested
+	if resourceURI != "" {
+		contents, err := sess.ReadResource(context.Background(), resourceURI)
+		if err != nil {
+			log.Fatalf("Failed to read resource: %v", err)
+		}
+		printResourceContents(os.Stdout, resourceURI, redact.Resources(contents))
+		return
+	}
+
+	// Call a single tool if one was requested
+	if toolName != "" {
+		result, err := sess.CallTool(context.Background(), toolName, toolArgs)
+		if err != nil {
+			log.Fatalf("Failed to call tool: %v", err)
+		}
+		result = redact.Result(result)
+		if saveDir != "" || preview {
+			if err := handleBinaryContent(result, saveDir, preview); err != nil {
+				log.Fatalf("Failed to save content: %v", err)
+			}
+		}
+		if outPath == "" && outFormat == "" {
+			printToolResult(os.Stdout, toolName, result)
+			return
+		}
+
+		// Export row data instead of printing the raw result
+		t, ok := resultTable(result)
+		if !ok {
+			printToolResult(os.Stderr, toolName, result)
+			log.Fatalf("Tool result holds no row data to export")
+		}
+		if outPath == "" {
+			outPath = "-"
+		}
+		if err := exportTable(outPath, outFormat, t); err != nil {
+			log.Fatalf("Failed to export result: %v", err)
+		}
+		if outPath != "-" {
+			log.Printf("Wrote %d rows to %s", len(t.Rows), outPath)
+		}
+		return
+	}
 
-	for _, tool := range tools.Tools {
-		logger.Printf("Name: %s Description: %s\n", tool.Name, tool.Description)
+	if _, err := printList(context.Background(), sess, listKind, os.Stdout); err != nil {
+		log.Fatalf("Failed to list %s: %v", listKind, err)
 	}
 }
@@ -0,0 +1,904 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ThinkInAIXYZ/go-mcp/protocol"
+)
+
+// Inspector tabs, in display order
+const (
+	tabTools = iota
+	tabResources
+	tabPrompts
+	tabHistory
+	tabCount
+)
+
+var tabNames = [tabCount]string{"Tools", "Resources", "Prompts", "History"}
+
+// focusArea is the part of the screen that receives keys.
+type focusArea int
+
+const (
+	focusList focusArea = iota
+	focusResult
+	focusForm
+	focusConfirm
+)
+
+// feedLines is how many lines of the notification and log feed are shown.
+const feedLines = 6
+
+// historyEntry is one request made from the inspector.
+type historyEntry struct {
+	kind string // "tool", "resource" or "prompt"
+	name string
+	args map[string]interface{}
+	at   time.Time
+	took time.Duration
+	err  error
+	view *jsonView
+}
+
+func (h *historyEntry) String() string {
+	status := "ok"
+	if h.err != nil {
+		status = "failed"
+	} else if h.view != nil && h.view.root.label == "error" {
+		status = "error"
+	}
+	return fmt.Sprintf("%s %s %s %s", h.at.Format("15:04:05"), h.kind, h.name, status)
+}
+
+// feed collects notifications and log output for the bottom pane. It is
+// written from other goroutines, so it wakes the UI instead of drawing.
+type feed struct {
+	mu    sync.Mutex
+	lines []string
+	wake  chan struct{}
+}
+
+func (f *feed) Write(p []byte) (int, error) {
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		f.add(line)
+	}
+	return len(p), nil
+}
+
+func (f *feed) add(line string) {
+	f.mu.Lock()
+	f.lines = append(f.lines, time.Now().Format("15:04:05")+" "+line)
+	if len(f.lines) > 500 {
+		f.lines = f.lines[len(f.lines)-500:]
+	}
+	f.mu.Unlock()
+	select {
+	case f.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (f *feed) last(n int) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.lines) < n {
+		n = len(f.lines)
+	}
+	return append([]string(nil), f.lines[len(f.lines)-n:]...)
+}
+
+// inspector is the state of the full-screen inspector. Everything but the
+// feed is only touched on the UI goroutine; background requests hand their
+// results back through events.
+type inspector struct {
+	a    *app
+	sess *session
+	url  string
+	term *terminal
+
+	tab   int
+	sel   [tabCount]int
+	focus focusArea
+
+	tools     []*protocol.Tool
+	resources []*protocol.Resource
+	prompts   []protocol.Prompt
+	history   []*historyEntry
+
+	form    *argForm
+	view    *jsonView
+	pending *historyEntry // waiting for confirmation
+	busy    string
+
+	feed      *feed
+	events    chan func()
+	assumeYes bool // --yes was given
+}
+
+// runInspectCommand implements "inspect": a full-screen view of the
+// server's tools, resources and prompts, or with -web the same in a
+// browser.
+func runInspectCommand(a *app, url string, args []string) error {
+	fs := flag.NewFlagSet("inspect", flag.ExitOnError)
+	web := fs.Bool("web", false, "Serve the inspector as a web page instead")
+	addr := fs.String("addr", "127.0.0.1:0", "Address the web inspector listens on")
+	fs.Parse(args)
+
+	if *web {
+		return runWebInspector(a, url, *addr)
+	}
+
+	// Notifications feed the log pane, and the daemon does not pass them on
+	a.noDaemon = true
+	sess, err := a.connect(url)
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	t, err := openTerminal()
+	if err != nil {
+		return err
+	}
+	defer t.Close()
+
+	in := &inspector{
+		a:         a,
+		sess:      sess,
+		url:       url,
+		term:      t,
+		feed:      &feed{wake: make(chan struct{}, 1)},
+		events:    make(chan func(), 16),
+		assumeYes: a.confirm.assumeYes,
+	}
+	// The inspector asks for confirmation itself; the confirmer would read
+	// from the raw terminal
+	a.confirm.assumeYes = true
+
+	log.SetOutput(redactingWriter{w: in.feed, r: a.redact})
+	defer log.SetOutput(redactingWriter{w: os.Stderr, r: a.redact})
+
+	in.load(false)
+	return in.loop()
+}
+
+// load fetches the catalogs in the background, bypassing the cache when
+// refresh is set.
+func (in *inspector) load(refresh bool) {
+	go func() {
+		ctx := context.Background()
+		if refresh {
+			in.sess.dropTools()
+			in.a.catalogs.invalidate(in.sess.server, "")
+		}
+		tools, err := in.sess.ListTools(ctx)
+		if err != nil {
+			log.Printf("List tools: %v", err)
+		}
+		resources, err := in.sess.ListResources(ctx)
+		if err != nil {
+			log.Printf("List resources: %v", err)
+		}
+		prompts, err := in.sess.ListPrompts(ctx)
+		if err != nil {
+			log.Printf("List prompts: %v", err)
+		}
+		in.events <- func() {
+			in.tools, in.resources, in.prompts = tools, resources, prompts
+		}
+	}()
+}
+
+func (in *inspector) loop() error {
+	keys := make(chan key, 16)
+	go in.term.readKeys(keys)
+	notes := in.sess.notifications()
+	resize := time.NewTicker(500 * time.Millisecond)
+	defer resize.Stop()
+
+	lastW, lastH := in.term.size()
+	dirty := true
+	for {
+		if dirty {
+			in.draw()
+		}
+		dirty = true
+		select {
+		case k, ok := <-keys:
+			if !ok || in.handleKey(k) {
+				return nil
+			}
+		case fn := <-in.events:
+			fn()
+		case n := <-notes:
+			in.feed.add(noteText(n))
+			if strings.HasSuffix(n.Method, "/list_changed") {
+				in.load(false)
+			}
+		case <-in.feed.wake:
+		case <-resize.C:
+			// Redraw only when the window size changed
+			w, h := in.term.size()
+			dirty = w != lastW || h != lastH
+			lastW, lastH = w, h
+		}
+	}
+}
+
+// noteText formats a notification for the feed.
+func noteText(n rpcNotification) string {
+	if len(n.Params) == 0 {
+		return "notify " + n.Method
+	}
+	return "notify " + n.Method + " " + string(n.Params)
+}
+
+// count returns the number of items on tab.
+func (in *inspector) count(tab int) int {
+	switch tab {
+	case tabTools:
+		return len(in.tools)
+	case tabResources:
+		return len(in.resources)
+	case tabPrompts:
+		return len(in.prompts)
+	}
+	return len(in.history)
+}
+
+// handleKey applies a key press and reports whether to quit.
+func (in *inspector) handleKey(k key) bool {
+	if k.code == keyCtrlC {
+		return true
+	}
+	switch in.focus {
+	case focusConfirm:
+		if k.code == keyRune && (k.r == 'y' || k.r == 'Y') {
+			entry := in.pending
+			in.pending, in.focus = nil, focusList
+			in.run(entry)
+		} else {
+			in.feed.add("Cancelled " + in.pending.kind + " " + in.pending.name)
+			in.pending, in.focus = nil, focusList
+		}
+	case focusForm:
+		in.formKey(k)
+	case focusResult:
+		switch {
+		case k.code == keyUp:
+			in.view.move(-1)
+		case k.code == keyDown:
+			in.view.move(1)
+		case k.code == keyPgUp:
+			in.view.move(-10)
+		case k.code == keyPgDn:
+			in.view.move(10)
+		case k.code == keyEnter || k.code == keyRune && k.r == ' ':
+			in.view.toggle()
+		case k.code == keyRune && k.r == '+':
+			in.view.setAll(true)
+		case k.code == keyRune && k.r == '-':
+			in.view.setAll(false)
+		case k.code == keyLeft || k.code == keyEsc || k.code == keyTab:
+			in.focus = focusList
+		case k.code == keyRune && k.r == 'q':
+			return true
+		}
+	default:
+		n := in.count(in.tab)
+		switch {
+		case k.code == keyRune && k.r == 'q':
+			return true
+		case k.code == keyTab || k.code == keyRune && k.r == 'l':
+			in.tab = (in.tab + 1) % tabCount
+		case k.code == keyBackTab || k.code == keyRune && k.r == 'h':
+			in.tab = (in.tab + tabCount - 1) % tabCount
+		case k.code == keyUp || k.code == keyRune && k.r == 'k':
+			if in.sel[in.tab] > 0 {
+				in.sel[in.tab]--
+			}
+		case k.code == keyDown || k.code == keyRune && k.r == 'j':
+			if in.sel[in.tab] < n-1 {
+				in.sel[in.tab]++
+			}
+		case k.code == keyRight:
+			if in.view != nil {
+				in.focus = focusResult
+			}
+		case k.code == keyEnter:
+			in.activate()
+		case k.code == keyRune && k.r == 'r' && in.tab == tabHistory && n > 0:
+			in.rerun(in.history[in.sel[tabHistory]])
+		case k.code == keyRune && k.r == 'e' && in.tab == tabHistory && n > 0:
+			in.edit(in.history[in.sel[tabHistory]])
+		case k.code == keyRune && k.r == 'R':
+			in.load(true)
+		}
+	}
+	return false
+}
+
+// activate acts on the selected item: open a form, read a resource or
+// show a past result.
+func (in *inspector) activate() {
+	if in.count(in.tab) == 0 {
+		return
+	}
+	i := in.sel[in.tab]
+	switch in.tab {
+	case tabTools:
+		form, err := toolForm(in.tools[i])
+		if err != nil {
+			in.feed.add(err.Error())
+			return
+		}
+		if len(form.fields) == 0 {
+			in.submit(form, nil)
+			return
+		}
+		in.form, in.focus = form, focusForm
+	case tabResources:
+		in.start(&historyEntry{kind: "resource", name: in.resources[i].URI})
+	case tabPrompts:
+		form := promptForm(in.prompts[i])
+		if len(form.fields) == 0 {
+			in.submit(form, nil)
+			return
+		}
+		in.form, in.focus = form, focusForm
+	case tabHistory:
+		if v := in.history[i].view; v != nil {
+			in.view, in.focus = v, focusResult
+		}
+	}
+}
+
+// rerun repeats a past request with the same arguments.
+func (in *inspector) rerun(h *historyEntry) {
+	in.start(&historyEntry{kind: h.kind, name: h.name, args: h.args})
+}
+
+// edit opens the form of a past tool or prompt call, filled in with its
+// arguments.
+func (in *inspector) edit(h *historyEntry) {
+	var form *argForm
+	switch h.kind {
+	case "tool":
+		for _, tool := range in.tools {
+			if tool.Name == h.name {
+				form, _ = toolForm(tool)
+			}
+		}
+	case "prompt":
+		for _, p := range in.prompts {
+			if p.Name == h.name {
+				form = promptForm(p)
+			}
+		}
+	}
+	if form == nil || len(form.fields) == 0 {
+		in.rerun(h)
+		return
+	}
+	form.fill(h.args)
+	in.form, in.focus = form, focusForm
+}
+
+func (in *inspector) formKey(k key) {
+	f := in.form
+	switch k.code {
+	case keyEsc:
+		in.form, in.focus = nil, focusList
+	case keyUp, keyBackTab:
+		if f.cursor > 0 {
+			f.cursor--
+		}
+	case keyDown, keyTab:
+		if f.cursor < len(f.fields)-1 {
+			f.cursor++
+		}
+	case keyBackspace:
+		if len(f.fields) == 0 {
+			return
+		}
+		field := f.fields[f.cursor]
+		if len(field.value) > 0 {
+			field.value = field.value[:len(field.value)-1]
+		}
+	case keyRune:
+		if len(f.fields) == 0 {
+			return
+		}
+		field := f.fields[f.cursor]
+		field.value = append(field.value, k.r)
+	case keyEnter:
+		args, err := f.values()
+		if err != nil {
+			f.err = err.Error()
+			return
+		}
+		in.form, in.focus = nil, focusList
+		in.submit(f, args)
+	}
+}
+
+func (in *inspector) submit(f *argForm, args map[string]interface{}) {
+	in.start(&historyEntry{kind: f.kind, name: f.name, args: args})
+}
+
+// start runs entry, asking first when it is a tool call that needs
+// confirmation.
+func (in *inspector) start(entry *historyEntry) {
+	if entry.kind == "tool" && !in.assumeYes && confirmRequired(context.Background(), in.sess, entry.name) {
+		in.pending, in.focus = entry, focusConfirm
+		return
+	}
+	in.run(entry)
+}
+
+// confirmRequired reports whether the confirmation policy covers the tool
+// the inspectors are about to call.
+func confirmRequired(ctx context.Context, sess *session, name string) bool {
+	catalog, err := sess.upstreamTools(ctx)
+	if err != nil {
+		return true
+	}
+	tool, err := sess.policy.resolve(name, catalog)
+	if err != nil {
+		return false // the call fails anyway
+	}
+	return sess.confirm.required(tool)
+}
+
+// run sends the request in the background and shows the result when it
+// arrives.
+func (in *inspector) run(entry *historyEntry) {
+	in.busy = entry.kind + " " + entry.name
+	go func() {
+		ctx := context.Background()
+		entry.at = time.Now()
+		var root *jsonNode
+		switch entry.kind {
+		case "tool":
+			var result *toolResult
+			if result, entry.err = in.sess.CallTool(ctx, entry.name, entry.args); entry.err == nil {
+				root = resultTree(in.a.redact.Result(result))
+			}
+		case "resource":
+			var contents []resourceContent
+			if contents, entry.err = in.sess.ReadResource(ctx, entry.name); entry.err == nil {
+				root = &jsonNode{label: "contents", container: true, open: true}
+				for _, c := range in.a.redact.Resources(contents) {
+					if c.Blob != "" {
+						root.children = append(root.children, &jsonNode{label: c.URI, raw: fmt.Sprintf("(%s blob, %d bytes base64)", c.MimeType, len(c.Blob))})
+						continue
+					}
+					root.children = append(root.children, textNode(c.URI, c.Text))
+				}
+			}
+		case "prompt":
+			strArgs := map[string]string{}
+			for k, v := range entry.args {
+				strArgs[k] = fmt.Sprint(v)
+			}
+			var p *promptResult
+			if p, entry.err = in.sess.GetPrompt(ctx, entry.name, strArgs); entry.err == nil {
+				data, _ := json.Marshal(p)
+				root = textNode("prompt", in.a.redact.JSONText(string(data)))
+			}
+		}
+		entry.took = time.Since(entry.at)
+		if entry.err != nil {
+			root = &jsonNode{label: "error", container: true, open: true, children: []*jsonNode{{raw: in.a.redact.Text(entry.err.Error())}}}
+		}
+		entry.view = newJSONView(entry.kind+" "+entry.name, root)
+
+		in.events <- func() {
+			in.busy = ""
+			in.history = append(in.history, entry)
+			in.sel[tabHistory] = len(in.history) - 1
+			in.view, in.focus = entry.view, focusResult
+			in.feed.add(fmt.Sprintf("%s %s took %s", entry.kind, entry.name, entry.took.Round(time.Millisecond)))
+		}
+	}()
+}
+
+// draw renders the whole screen.
+func (in *inspector) draw() {
+	width, height := in.term.size()
+	leftW := width / 3
+	if leftW > 40 {
+		leftW = 40
+	}
+	rightW := width - leftW - 1
+	mainH := height - 3 - feedLines
+	if mainH < 3 {
+		mainH = 3
+	}
+
+	lines := make([]string, 0, height)
+
+	// Tab bar
+	var tabs strings.Builder
+	for i, name := range tabNames {
+		label := fmt.Sprintf(" %s (%d) ", name, in.count(i))
+		if i == in.tab {
+			label = reverse(label)
+		}
+		tabs.WriteString(label)
+	}
+	lines = append(lines, tabs.String()+dim(" "+in.url))
+
+	left := in.drawList(leftW, mainH)
+	right := in.drawRight(rightW, mainH)
+	for i := 0; i < mainH; i++ {
+		lines = append(lines, left[i]+dim("│")+right[i])
+	}
+
+	lines = append(lines, dim(fit("── Notifications and log ", width)))
+	feed := in.feed.last(feedLines)
+	for i := 0; i < feedLines; i++ {
+		text := ""
+		if i < len(feed) {
+			text = feed[i]
+		}
+		lines = append(lines, fit(text, width))
+	}
+	lines = append(lines, reverse(fit(in.statusLine(), width)))
+	in.term.draw(lines)
+}
+
+func (in *inspector) statusLine() string {
+	var help string
+	switch in.focus {
+	case focusList:
+		help = "↑↓ select  Tab next tab  Enter open  → result  R reload  q quit"
+		if in.tab == tabHistory {
+			help = "↑↓ select  Enter show  r re-run  e edit  Tab next tab  q quit"
+		}
+	case focusResult:
+		help = "↑↓ move  Space fold  + expand all  - fold all  ← back  q quit"
+	case focusForm:
+		help = "↑↓ field  type to edit  Enter call  Esc cancel"
+	case focusConfirm:
+		help = "y confirm  any other key cancels"
+	}
+	if in.busy != "" {
+		help = "Running " + in.busy + "…  " + help
+	}
+	return " " + help
+}
+
+func (in *inspector) drawList(width, height int) []string {
+	var items []string
+	switch in.tab {
+	case tabTools:
+		for _, t := range in.tools {
+			items = append(items, t.Name)
+		}
+	case tabResources:
+		for _, r := range in.resources {
+			items = append(items, r.URI)
+		}
+	case tabPrompts:
+		for _, p := range in.prompts {
+			items = append(items, p.Name)
+		}
+	case tabHistory:
+		for _, h := range in.history {
+			items = append(items, h.String())
+		}
+	}
+
+	sel := in.sel[in.tab]
+	top := 0
+	if sel >= height {
+		top = sel - height + 1
+	}
+	out := make([]string, 0, height)
+	for i := top; i < len(items) && len(out) < height; i++ {
+		line := fit(" "+items[i], width)
+		if i == sel {
+			if in.focus == focusList {
+				line = reverse(line)
+			} else {
+				line = bold(line)
+			}
+		}
+		out = append(out, line)
+	}
+	for len(out) < height {
+		out = append(out, strings.Repeat(" ", width))
+	}
+	return out
+}
+
+func (in *inspector) drawRight(width, height int) []string {
+	var text []string
+	switch {
+	case in.focus == focusConfirm:
+		args, _ := json.MarshalIndent(in.a.redact.Args(in.pending.args), "", "  ")
+		text = append(text, bold("Confirm call"), "", "Tool "+in.pending.name+" is marked destructive or needs confirmation.", "Arguments:")
+		text = append(text, strings.Split(string(args), "\n")...)
+		text = append(text, "", "Proceed? [y/N]")
+	case in.focus == focusForm:
+		text = in.form.render(width)
+	case in.focus == focusResult && in.view != nil:
+		header := fit(" "+in.view.title, width)
+		return append([]string{bold(header)}, in.view.render(width, height-1, true)...)
+	case in.tab == tabHistory && in.count(tabHistory) > 0:
+		h := in.history[in.sel[tabHistory]]
+		header := fit(fmt.Sprintf(" %s %s (%s)", h.kind, h.name, h.took.Round(time.Millisecond)), width)
+		return append([]string{bold(header)}, h.view.render(width, height-1, false)...)
+	default:
+		text = in.details(width)
+	}
+
+	out := make([]string, 0, height)
+	for _, line := range text {
+		if len(out) == height {
+			break
+		}
+		out = append(out, fit(" "+line, width))
+	}
+	for len(out) < height {
+		out = append(out, strings.Repeat(" ", width))
+	}
+	return out
+}
+
+// details describes the selected tool, resource or prompt.
+func (in *inspector) details(width int) []string {
+	if in.count(in.tab) == 0 {
+		return []string{"Nothing here yet."}
+	}
+	i := in.sel[in.tab]
+	var out []string
+	switch in.tab {
+	case tabTools:
+		t := in.tools[i]
+		out = append(out, bold(t.Name))
+		out = append(out, wrapText(t.Description, width-2)...)
+		form, err := toolForm(t)
+		if err == nil && len(form.fields) > 0 {
+			out = append(out, "", "Arguments:")
+			for _, f := range form.fields {
+				out = append(out, "  "+f.label())
+				if f.description != "" {
+					for _, line := range wrapText(f.description, width-6) {
+						out = append(out, "    "+dim(line))
+					}
+				}
+			}
+		}
+		if a := t.Annotations; a != nil {
+			out = append(out, "", fmt.Sprintf("readOnly %v  destructive %v  idempotent %v  openWorld %v",
+				annotationHint(t, "readOnlyHint"), annotationHint(t, "destructiveHint"),
+				annotationHint(t, "idempotentHint"), annotationHint(t, "openWorldHint")))
+		}
+	case tabResources:
+		r := in.resources[i]
+		out = append(out, bold(r.Name), r.URI)
+		if r.MimeType != "" {
+			out = append(out, r.MimeType)
+		}
+		out = append(out, "")
+		out = append(out, wrapText(r.Description, width-2)...)
+	case tabPrompts:
+		p := in.prompts[i]
+		out = append(out, bold(p.Name))
+		out = append(out, wrapText(p.Description, width-2)...)
+		if len(p.Arguments) > 0 {
+			out = append(out, "", "Arguments:")
+			for _, arg := range p.Arguments {
+				req := ""
+				if arg.Required {
+					req = " (required)"
+				}
+				out = append(out, "  "+arg.Name+req)
+			}
+		}
+	}
+	return out
+}
+
+// wrapText breaks s into lines of at most width characters.
+func wrapText(s string, width int) []string {
+	if width < 10 {
+		width = 10
+	}
+	var out []string
+	for _, para := range strings.Split(s, "\n") {
+		line := ""
+		for _, word := range strings.Fields(para) {
+			if line != "" && len([]rune(line))+1+len([]rune(word)) > width {
+				out = append(out, line)
+				line = ""
+			}
+			if line != "" {
+				line += " "
+			}
+			line += word
+		}
+		out = append(out, line)
+	}
+	return out
+}
+
+// formField is one argument of a tool or prompt form.
+type formField struct {
+	name        string
+	typ         string // JSON Schema type; prompts only have strings
+	description string
+	required    bool
+	value       []rune
+}
+
+func (f *formField) label() string {
+	s := f.name + " (" + f.typ
+	if f.required {
+		s += ", required"
+	}
+	return s + ")"
+}
+
+// argForm collects the arguments of a tool call or prompt.
+type argForm struct {
+	kind   string // "tool" or "prompt"
+	name   string
+	fields []*formField
+	cursor int
+	err    string
+}
+
+// toolForm builds a form from the properties of a tool's input schema.
+func toolForm(tool *protocol.Tool) (*argForm, error) {
+	form := &argForm{kind: "tool", name: tool.Name}
+	data, err := json.Marshal(tool)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	v, err := decodeOrdered(dec)
+	if err != nil {
+		return nil, err
+	}
+	obj, _ := v.(*orderedObject)
+	if obj == nil {
+		return form, nil
+	}
+	schema, _ := obj.values["inputSchema"].(*orderedObject)
+	if schema == nil {
+		return form, nil
+	}
+	required := map[string]bool{}
+	if list, ok := schema.values["required"].([]interface{}); ok {
+		for _, name := range list {
+			if s, ok := name.(string); ok {
+				required[s] = true
+			}
+		}
+	}
+	props, _ := schema.values["properties"].(*orderedObject)
+	if props == nil {
+		return form, nil
+	}
+	for _, name := range props.keys {
+		field := &formField{name: name, typ: "string", required: required[name]}
+		if prop, ok := props.values[name].(*orderedObject); ok {
+			if t, ok := prop.values["type"].(string); ok {
+				field.typ = t
+			}
+			if d, ok := prop.values["description"].(string); ok {
+				field.description = d
+			}
+		}
+		form.fields = append(form.fields, field)
+	}
+	return form, nil
+}
+
+func promptForm(p protocol.Prompt) *argForm {
+	form := &argForm{kind: "prompt", name: p.Name}
+	for _, arg := range p.Arguments {
+		form.fields = append(form.fields, &formField{name: arg.Name, typ: "string", description: arg.Description, required: arg.Required})
+	}
+	return form
+}
+
+// fill sets the fields from earlier arguments.
+func (f *argForm) fill(args map[string]interface{}) {
+	for _, field := range f.fields {
+		v, ok := args[field.name]
+		if !ok {
+			continue
+		}
+		if s, ok := v.(string); ok {
+			field.value = []rune(s)
+			continue
+		}
+		data, _ := json.Marshal(v)
+		field.value = []rune(string(data))
+	}
+}
+
+// values converts the fields to arguments by their schema type. Empty
+// optional fields are left out.
+func (f *argForm) values() (map[string]interface{}, error) {
+	args := map[string]interface{}{}
+	for _, field := range f.fields {
+		s := string(field.value)
+		if s == "" {
+			if field.required {
+				return nil, fmt.Errorf("%s is required", field.name)
+			}
+			continue
+		}
+		switch field.typ {
+		case "integer":
+			n, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s must be an integer", field.name)
+			}
+			args[field.name] = n
+		case "number":
+			n, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s must be a number", field.name)
+			}
+			args[field.name] = n
+		case "boolean":
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				return nil, fmt.Errorf("%s must be true or false", field.name)
+			}
+			args[field.name] = b
+		case "object", "array":
+			var v interface{}
+			if err := json.Unmarshal([]byte(s), &v); err != nil {
+				return nil, fmt.Errorf("%s must be JSON: %v", field.name, err)
+			}
+			args[field.name] = v
+		default:
+			args[field.name] = s
+		}
+	}
+	return args, nil
+}
+
+func (f *argForm) render(width int) []string {
+	out := []string{bold("Call " + f.kind + " " + f.name), ""}
+	for i, field := range f.fields {
+		value := string(field.value)
+		if i == f.cursor {
+			value += "▏"
+		}
+		label := field.label() + ": "
+		line := fit(label+value, width-2)
+		if i == f.cursor {
+			line = reverse(line)
+		}
+		out = append(out, line)
+		if i == f.cursor && field.description != "" {
+			for _, d := range wrapText(field.description, width-4) {
+				out = append(out, "  "+dim(d))
+			}
+		}
+	}
+	if f.err != "" {
+		out = append(out, "", "Error: "+f.err)
+	}
+	return out
+}
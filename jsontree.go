@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// jsonNode is a node of a foldable JSON view. Containers have children;
+// leaves have a value, or raw text for lines of a plain-text result.
+type jsonNode struct {
+	label     string
+	container bool
+	array     bool
+	open      bool
+	children  []*jsonNode
+	value     interface{}
+	raw       string
+}
+
+// newJSONNode builds a node from a value decoded by decodeOrdered. The
+// first two levels start open.
+func newJSONNode(label string, v interface{}, depth int) *jsonNode {
+	n := &jsonNode{label: label}
+	switch v := v.(type) {
+	case *orderedObject:
+		n.container, n.open = true, depth < 2
+		for _, k := range v.keys {
+			n.children = append(n.children, newJSONNode(k, v.values[k], depth+1))
+		}
+	case []interface{}:
+		n.container, n.array, n.open = true, true, depth < 2
+		for i, item := range v {
+			n.children = append(n.children, newJSONNode(fmt.Sprint(i), item, depth+1))
+		}
+	default:
+		n.value = v
+	}
+	return n
+}
+
+// textNode shows text as JSON when it parses as an object or array, and
+// as its lines otherwise.
+func textNode(label, text string) *jsonNode {
+	trimmed := bytes.TrimSpace([]byte(text))
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		dec := json.NewDecoder(bytes.NewReader(trimmed))
+		dec.UseNumber()
+		if v, err := decodeOrdered(dec); err == nil {
+			return newJSONNode(label, v, 0)
+		}
+	}
+	n := &jsonNode{label: label, container: true, open: true}
+	for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		n.children = append(n.children, &jsonNode{raw: line})
+	}
+	return n
+}
+
+// resultTree lays out a tool result: its structured content, then each
+// content item.
+func resultTree(r *toolResult) *jsonNode {
+	root := &jsonNode{label: "result", container: true, open: true}
+	if r.IsError {
+		root.label = "error"
+	}
+	if len(r.StructuredContent) > 0 && string(r.StructuredContent) != "null" {
+		root.children = append(root.children, textNode("structuredContent", string(r.StructuredContent)))
+	}
+	for i, c := range r.Content {
+		label := fmt.Sprintf("content[%d]", i)
+		switch {
+		case c.Type == "text":
+			root.children = append(root.children, textNode(label, c.Text))
+		case c.Resource != nil && c.Resource.Text != "":
+			root.children = append(root.children, textNode(label+" "+c.Resource.URI, c.Resource.Text))
+		default:
+			root.children = append(root.children, &jsonNode{label: label, raw: fmt.Sprintf("(%s %s, %d bytes base64)", c.Type, c.MimeType, len(c.Data))})
+		}
+	}
+	return root
+}
+
+// jsonLine is one visible line of a jsonView.
+type jsonLine struct {
+	node  *jsonNode
+	depth int
+}
+
+// jsonView is a scrollable, foldable rendering of a jsonNode tree.
+type jsonView struct {
+	title  string
+	root   *jsonNode
+	lines  []jsonLine
+	cursor int
+	top    int
+}
+
+func newJSONView(title string, root *jsonNode) *jsonView {
+	v := &jsonView{title: title, root: root}
+	v.layout()
+	return v
+}
+
+// layout recomputes the visible lines after folding changes.
+func (v *jsonView) layout() {
+	v.lines = v.lines[:0]
+	var walk func(n *jsonNode, depth int)
+	walk = func(n *jsonNode, depth int) {
+		v.lines = append(v.lines, jsonLine{node: n, depth: depth})
+		if n.container && n.open {
+			for _, c := range n.children {
+				walk(c, depth+1)
+			}
+		}
+	}
+	walk(v.root, 0)
+	if v.cursor >= len(v.lines) {
+		v.cursor = len(v.lines) - 1
+	}
+}
+
+// toggle opens or closes the container under the cursor.
+func (v *jsonView) toggle() {
+	if n := v.lines[v.cursor].node; n.container {
+		n.open = !n.open
+		v.layout()
+	}
+}
+
+// setAll opens or closes every container below the root.
+func (v *jsonView) setAll(open bool) {
+	var walk func(n *jsonNode)
+	walk = func(n *jsonNode) {
+		if n.container {
+			n.open = open
+		}
+		for _, c := range n.children {
+			walk(c)
+		}
+	}
+	for _, c := range v.root.children {
+		walk(c)
+	}
+	v.layout()
+}
+
+func (v *jsonView) move(delta int) {
+	v.cursor += delta
+	if v.cursor >= len(v.lines) {
+		v.cursor = len(v.lines) - 1
+	}
+	if v.cursor < 0 {
+		v.cursor = 0
+	}
+}
+
+// render returns height lines of width characters.
+func (v *jsonView) render(width, height int, focused bool) []string {
+	if v.cursor < v.top {
+		v.top = v.cursor
+	}
+	if v.cursor >= v.top+height {
+		v.top = v.cursor - height + 1
+	}
+	out := make([]string, 0, height)
+	for i := v.top; i < len(v.lines) && len(out) < height; i++ {
+		line := fit(v.lines[i].text(), width)
+		if focused && i == v.cursor {
+			line = reverse(line)
+		}
+		out = append(out, line)
+	}
+	for len(out) < height {
+		out = append(out, strings.Repeat(" ", width))
+	}
+	return out
+}
+
+func (l jsonLine) text() string {
+	n := l.node
+	indent := strings.Repeat("  ", l.depth)
+	label := ""
+	if n.label != "" {
+		label = n.label + ": "
+	}
+	switch {
+	case n.container:
+		marker, summary := "▾ ", ""
+		if !n.open {
+			marker = "▸ "
+			summary = fmt.Sprintf(" {%d keys}", len(n.children))
+			if n.array {
+				summary = fmt.Sprintf(" [%d items]", len(n.children))
+			}
+		}
+		return indent + marker + strings.TrimSuffix(label, ": ") + summary
+	case n.raw != "" || n.value == nil && n.label == "":
+		return indent + "  " + label + n.raw
+	}
+	data, _ := json.Marshal(n.value)
+	return indent + "  " + label + string(data)
+}
@@ -0,0 +1,425 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ThinkInAIXYZ/go-mcp/transport"
+)
+
+// middleware wraps the transport of a connection to observe or change the
+// messages passing through it. In a chain the first middleware is
+// outermost: it sees outgoing messages first and incoming ones last.
+// A wrapping transport should have an unwrap method returning next, so
+// the mux can still learn when the connection underneath ends.
+type middleware func(next transport.ClientTransport) transport.ClientTransport
+
+// middlewareFactory builds a middleware from the options of a config
+// entry or -middleware flag. It may register cleanup with a.onClose.
+type middlewareFactory func(a *app, opts json.RawMessage) (middleware, error)
+
+var (
+	middlewaresMu sync.Mutex
+	middlewares   = map[string]middlewareFactory{}
+)
+
+// registerMiddleware makes a middleware available by name to the config
+// file and the -middleware flag.
+//
+// The client is a single main package without a module of its own, so no
+// other program can import the chain or call this. Custom middleware is
+// added by dropping a file into this package whose init function
+// registers it, as the built-in ones are below. Registering middleware
+// from outside needs the transport layer split into an importable
+// package first, which is not done here.
+func registerMiddleware(name string, f middlewareFactory) {
+	middlewaresMu.Lock()
+	defer middlewaresMu.Unlock()
+	if _, dup := middlewares[name]; dup {
+		panic("middleware " + name + " registered twice")
+	}
+	middlewares[name] = f
+}
+
+func init() {
+	registerMiddleware("trace", newTraceMiddleware)
+	registerMiddleware("record", newRecordMiddleware)
+	registerMiddleware("retry", newRetryMiddleware)
+	registerMiddleware("faults", newFaultsMiddleware)
+}
+
+// middlewareConfig is one entry of the "middleware" list in the config
+// file: the name of a registered middleware and its options.
+//
+//	"middleware": [{"name": "record", "path": "session.jsonl"}, {"name": "retry", "attempts": 3}]
+type middlewareConfig struct {
+	Name    string
+	Options json.RawMessage // the whole entry
+}
+
+func (c *middlewareConfig) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	c.Name, c.Options = v.Name, append(json.RawMessage(nil), data...)
+	return nil
+}
+
+// parseMiddlewareFlag turns "name" or "name:key=value,key=value" into a
+// config entry. Values that parse as numbers or booleans are passed as
+// such.
+func parseMiddlewareFlag(s string) (middlewareConfig, error) {
+	name, rest, _ := strings.Cut(s, ":")
+	opts := map[string]interface{}{"name": name}
+	if rest != "" {
+		for _, kv := range strings.Split(rest, ",") {
+			k, v, ok := strings.Cut(kv, "=")
+			if !ok || k == "" {
+				return middlewareConfig{}, fmt.Errorf("middleware %s: expected key=value, got %q", name, kv)
+			}
+			if n, err := strconv.ParseFloat(v, 64); err == nil {
+				opts[k] = n
+			} else if b, err := strconv.ParseBool(v); err == nil {
+				opts[k] = b
+			} else {
+				opts[k] = v
+			}
+		}
+	}
+	data, _ := json.Marshal(opts)
+	return middlewareConfig{Name: name, Options: data}, nil
+}
+
+// middlewareList collects repeated -middleware flags.
+type middlewareList []string
+
+func (l *middlewareList) String() string { return strings.Join(*l, " ") }
+
+func (l *middlewareList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+// addMiddleware checks the configured middleware names and queues them
+// for the chain. They are built on the first direct connection, so a
+// call routed through the daemon opens no record file here; the daemon
+// builds its own.
+func (a *app) addMiddleware(cfgs []middlewareConfig) error {
+	for _, c := range cfgs {
+		if _, ok := lookupMiddleware(c.Name); !ok {
+			return fmt.Errorf("unknown middleware %q (have %s)", c.Name, strings.Join(middlewareNames(), ", "))
+		}
+	}
+	a.middlewareCfgs = append(a.middlewareCfgs, cfgs...)
+	return nil
+}
+
+// middlewareChain returns the chain for a direct connection: the
+// configured middleware, built on the first call, then that added with
+// use.
+func (a *app) middlewareChain() ([]middleware, error) {
+	a.buildOnce.Do(func() {
+		for _, c := range a.middlewareCfgs {
+			f, _ := lookupMiddleware(c.Name)
+			mw, err := f(a, c.Options)
+			if err != nil {
+				a.buildErr = fmt.Errorf("middleware %s: %w", c.Name, err)
+				return
+			}
+			a.configured = append(a.configured, mw)
+		}
+	})
+	if a.buildErr != nil {
+		return nil, a.buildErr
+	}
+	return append(append([]middleware(nil), a.configured...), a.middleware...), nil
+}
+
+func lookupMiddleware(name string) (middlewareFactory, bool) {
+	middlewaresMu.Lock()
+	defer middlewaresMu.Unlock()
+	f, ok := middlewares[name]
+	return f, ok
+}
+
+func middlewareNames() []string {
+	middlewaresMu.Lock()
+	defer middlewaresMu.Unlock()
+	var names []string
+	for name := range middlewares {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// use appends middleware to the chain of connections made from now on,
+// inside any configured middleware.
+func (a *app) use(mw ...middleware) {
+	a.middleware = append(a.middleware, mw...)
+}
+
+// wrapTransport applies chain to t, the first middleware outermost.
+func wrapTransport(t transport.ClientTransport, chain []middleware) transport.ClientTransport {
+	for i := len(chain) - 1; i >= 0; i-- {
+		t = chain[i](t)
+	}
+	return t
+}
+
+// messageHooks builds a middleware from functions on single messages.
+// send sees each request and notification on its way to the server;
+// receive sees each response, notification and request from it. Either
+// may be nil, and either may return a changed message, or nil to drop it.
+func messageHooks(send func(ctx context.Context, msg []byte) ([]byte, error), receive func(ctx context.Context, msg []byte) []byte) middleware {
+	return func(next transport.ClientTransport) transport.ClientTransport {
+		return &hookTransport{ClientTransport: next, send: send, receive: receive}
+	}
+}
+
+type hookTransport struct {
+	transport.ClientTransport
+	send    func(ctx context.Context, msg []byte) ([]byte, error)
+	receive func(ctx context.Context, msg []byte) []byte
+}
+
+func (t *hookTransport) unwrap() transport.ClientTransport { return t.ClientTransport }
+
+func (t *hookTransport) Send(ctx context.Context, msg transport.Message) error {
+	if t.send != nil {
+		out, err := t.send(ctx, msg)
+		if err != nil || out == nil {
+			return err
+		}
+		msg = out
+	}
+	return t.ClientTransport.Send(ctx, msg)
+}
+
+func (t *hookTransport) SetReceiver(r transport.ClientReceiver) {
+	if t.receive == nil {
+		t.ClientTransport.SetReceiver(r)
+		return
+	}
+	t.ClientTransport.SetReceiver(transport.ClientReceiverF(func(ctx context.Context, msg []byte) error {
+		if msg = t.receive(ctx, msg); msg == nil {
+			return nil
+		}
+		return r.Receive(ctx, msg)
+	}))
+}
+
+// messageTracer is called with each JSON-RPC message sent (outgoing) or
+// received on a connection.
+type messageTracer func(outgoing bool, msg []byte)
+
+// observe returns a middleware that shows every message to fn unchanged.
+func observe(fn messageTracer) middleware {
+	return messageHooks(
+		func(ctx context.Context, msg []byte) ([]byte, error) {
+			fn(true, msg)
+			return msg, nil
+		},
+		func(ctx context.Context, msg []byte) []byte {
+			fn(false, msg)
+			return msg
+		})
+}
+
+// newTraceMiddleware logs every message, redacted.
+//
+//	{"name": "trace"}
+func newTraceMiddleware(a *app, _ json.RawMessage) (middleware, error) {
+	return observe(func(outgoing bool, msg []byte) {
+		arrow := "<-"
+		if outgoing {
+			arrow = "->"
+		}
+		log.Printf("%s %s", arrow, a.redact.JSONText(string(bytes.TrimSpace(msg))))
+	}), nil
+}
+
+// newRecordMiddleware appends every message, redacted, to a JSON Lines
+// file, one {"time", "direction", "message"} object per line.
+//
+//	{"name": "record", "path": "session.jsonl"}
+func newRecordMiddleware(a *app, opts json.RawMessage) (middleware, error) {
+	var o struct {
+		Path string `json:"path"`
+	}
+	if err := json.Unmarshal(opts, &o); err != nil {
+		return nil, err
+	}
+	if o.Path == "" {
+		return nil, errors.New("path is required")
+	}
+	f, err := os.OpenFile(o.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	a.onClose(f.Close)
+
+	var mu sync.Mutex
+	enc := json.NewEncoder(f)
+	return observe(func(outgoing bool, msg []byte) {
+		rec := struct {
+			Time      time.Time       `json:"time"`
+			Direction string          `json:"direction"`
+			Message   json.RawMessage `json:"message"`
+		}{Time: time.Now(), Direction: "recv"}
+		if outgoing {
+			rec.Direction = "send"
+		}
+		text := a.redact.JSONText(string(bytes.TrimSpace(msg)))
+		if json.Valid([]byte(text)) {
+			rec.Message = json.RawMessage(text)
+		} else {
+			rec.Message, _ = json.Marshal(text)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if err := enc.Encode(rec); err != nil {
+			log.Printf("Failed to record message: %v", err)
+		}
+	}), nil
+}
+
+// newRetryMiddleware resends a message when the transport fails to send
+// it, waiting backoff and then twice as long each time. A send error does
+// not prove the server never got the message: an HTTP request can fail
+// after the server has acted on it. So only notifications and requests
+// safe to repeat (listings, ping, resources/read and prompts/get) are
+// retried by default; tool calls, which may run twice, only with
+// "toolCalls": true.
+//
+//	{"name": "retry", "attempts": 3, "backoff": "200ms", "toolCalls": false}
+func newRetryMiddleware(a *app, opts json.RawMessage) (middleware, error) {
+	o := struct {
+		Attempts  int    `json:"attempts"`
+		Backoff   string `json:"backoff"`
+		ToolCalls bool   `json:"toolCalls"`
+	}{Attempts: 3, Backoff: "200ms"}
+	if err := json.Unmarshal(opts, &o); err != nil {
+		return nil, err
+	}
+	backoff, err := time.ParseDuration(o.Backoff)
+	if err != nil {
+		return nil, fmt.Errorf("backoff: %w", err)
+	}
+	return func(next transport.ClientTransport) transport.ClientTransport {
+		return &retryTransport{ClientTransport: next, attempts: o.Attempts, backoff: backoff, toolCalls: o.ToolCalls}
+	}, nil
+}
+
+type retryTransport struct {
+	transport.ClientTransport
+	attempts  int
+	backoff   time.Duration
+	toolCalls bool
+}
+
+func (t *retryTransport) unwrap() transport.ClientTransport { return t.ClientTransport }
+
+// retryable reports whether msg may be sent again after a failed send.
+func (t *retryTransport) retryable(msg []byte) bool {
+	var m struct {
+		ID     json.RawMessage `json:"id"`
+		Method string          `json:"method"`
+	}
+	if err := json.Unmarshal(msg, &m); err != nil {
+		return false
+	}
+	switch {
+	case m.ID == nil:
+		return true
+	case strings.HasSuffix(m.Method, "/list"), m.Method == "ping", m.Method == "resources/read", m.Method == "prompts/get":
+		return true
+	case m.Method == "tools/call":
+		return t.toolCalls
+	}
+	return false
+}
+
+func (t *retryTransport) Send(ctx context.Context, msg transport.Message) error {
+	if !t.retryable(msg) {
+		return t.ClientTransport.Send(ctx, msg)
+	}
+	wait := t.backoff
+	for attempt := 1; ; attempt++ {
+		err := t.ClientTransport.Send(ctx, msg)
+		if err == nil || attempt >= t.attempts || ctx.Err() != nil {
+			return err
+		}
+		log.Printf("Send failed, retrying in %s: %v", wait, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+}
+
+// newFaultsMiddleware injects failures for testing how servers and
+// workflows cope: failed sends, dropped incoming messages and delays.
+// Probabilities are between 0 and 1.
+//
+//	{"name": "faults", "sendError": 0.1, "drop": 0.05, "delay": "250ms", "seed": 1}
+func newFaultsMiddleware(a *app, opts json.RawMessage) (middleware, error) {
+	var o struct {
+		SendError float64 `json:"sendError"`
+		Drop      float64 `json:"drop"`
+		Delay     string  `json:"delay"`
+		Seed      int64   `json:"seed"`
+	}
+	if err := json.Unmarshal(opts, &o); err != nil {
+		return nil, err
+	}
+	var delay time.Duration
+	if o.Delay != "" {
+		var err error
+		if delay, err = time.ParseDuration(o.Delay); err != nil {
+			return nil, fmt.Errorf("delay: %w", err)
+		}
+	}
+	if o.Seed == 0 {
+		o.Seed = time.Now().UnixNano()
+	}
+	var mu sync.Mutex
+	rng := rand.New(rand.NewSource(o.Seed))
+	chance := func(p float64) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return rng.Float64() < p
+	}
+	log.Printf("Injecting faults: send errors %.0f%%, drops %.0f%%, delay %s", o.SendError*100, o.Drop*100, delay)
+
+	return messageHooks(
+		func(ctx context.Context, msg []byte) ([]byte, error) {
+			if chance(o.SendError) {
+				return nil, errors.New("injected send failure")
+			}
+			return msg, nil
+		},
+		func(ctx context.Context, msg []byte) []byte {
+			if chance(o.Drop) {
+				return nil
+			}
+			time.Sleep(delay)
+			return msg
+		}), nil
+}
@@ -0,0 +1,478 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/ThinkInAIXYZ/go-mcp/protocol"
+	"github.com/ThinkInAIXYZ/go-mcp/transport"
+)
+
+// mcpProtocolVersion is the MCP revision sent in initialize.
+const mcpProtocolVersion = "2025-03-26"
+
+// defaultMaxInFlight bounds the requests outstanding on one connection.
+const defaultMaxInFlight = 32
+
+var errMuxClosed = errors.New("connection closed")
+
+// rpcError is a JSON-RPC error returned by the server.
+type rpcError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("rpc error %d: %s", e.Code, e.Message)
+}
+
+// rpcMessage is any JSON-RPC message: a request has a method and an ID, a
+// notification a method only, and a response an ID only.
+type rpcMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// closeNotifier is implemented by transports that notice on their own
+// when the connection ends, such as a socket whose read fails. fn is
+// called once with the cause; if the connection already ended, at once.
+type closeNotifier interface {
+	onClose(fn func(error))
+}
+
+// unwrapper is implemented by transports that wrap another, so the mux can
+// find a closeNotifier underneath middleware.
+type unwrapper interface {
+	unwrap() transport.ClientTransport
+}
+
+// closeHook implements closeNotifier for a transport.
+type closeHook struct {
+	mu    sync.Mutex
+	fn    func(error)
+	ended bool
+	err   error
+}
+
+func (h *closeHook) onClose(fn func(error)) {
+	h.mu.Lock()
+	h.fn = fn
+	ended, err := h.ended, h.err
+	h.mu.Unlock()
+	if ended {
+		fn(err)
+	}
+}
+
+// fire reports that the connection ended with err.
+func (h *closeHook) fire(err error) {
+	h.mu.Lock()
+	if h.ended {
+		h.mu.Unlock()
+		return
+	}
+	h.ended, h.err = true, err
+	fn := h.fn
+	h.mu.Unlock()
+	if fn != nil {
+		fn(err)
+	}
+}
+
+// notificationHandler is called for each notification from the server.
+type notificationHandler func(ctx context.Context, method string, params json.RawMessage)
+
+// rpcMux runs an MCP session over one transport and lets any number of
+// goroutines issue requests at once. Responses are matched to requests by
+// JSON-RPC ID, so the server may answer in any order. At most maxInFlight
+// requests are outstanding; further callers wait for a slot. Notifications
+// are handed to the handler one at a time in the order they arrived, on a
+// goroutine of their own, so a slow handler never holds up responses.
+// When the transport reports that the connection ended, every outstanding
+// and later request fails.
+type rpcMux struct {
+	t        transport.ClientTransport
+	slots    chan struct{}
+	onNotify notificationHandler
+
+	mu      sync.Mutex
+	nextID  int64
+	pending map[int64]chan *rpcMessage
+	closed  bool
+	err     error // why the session ended, once closed
+
+	// Notification queue, drained by dispatch
+	queueMu sync.Mutex
+	queue   []*rpcMessage
+	wake    chan struct{}
+	done    chan struct{} // closed when the session ends
+
+	serverInfo   protocol.Implementation
+	capabilities protocol.ServerCapabilities
+}
+
+// newRPCMux starts t and performs the MCP initialize handshake.
+func newRPCMux(ctx context.Context, t transport.ClientTransport, maxInFlight int, onNotify notificationHandler) (*rpcMux, error) {
+	if maxInFlight <= 0 {
+		maxInFlight = defaultMaxInFlight
+	}
+	m := &rpcMux{
+		t:        t,
+		slots:    make(chan struct{}, maxInFlight),
+		onNotify: onNotify,
+		pending:  make(map[int64]chan *rpcMessage),
+		wake:     make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+	t.SetReceiver(transport.ClientReceiverF(func(ctx context.Context, msg []byte) error {
+		m.receive(msg)
+		return nil
+	}))
+	for inner := t; inner != nil; {
+		if c, ok := inner.(closeNotifier); ok {
+			c.onClose(func(err error) {
+				m.fail(fmt.Errorf("%w: %v", errMuxClosed, err))
+			})
+			break
+		}
+		u, ok := inner.(unwrapper)
+		if !ok {
+			break
+		}
+		inner = u.unwrap()
+	}
+	if err := t.Start(); err != nil {
+		return nil, fmt.Errorf("start transport: %w", err)
+	}
+	go m.dispatch()
+
+	if err := m.initialize(ctx); err != nil {
+		m.Close()
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *rpcMux) initialize(ctx context.Context) error {
+	params := map[string]interface{}{
+		"protocolVersion": mcpProtocolVersion,
+		"capabilities":    map[string]interface{}{},
+		"clientInfo":      protocol.Implementation{Name: "mcp-client-examples", Version: "0.1.0"},
+	}
+	var result struct {
+		ServerInfo   protocol.Implementation     `json:"serverInfo"`
+		Capabilities protocol.ServerCapabilities `json:"capabilities"`
+	}
+	if err := m.call(ctx, "initialize", params, &result); err != nil {
+		return fmt.Errorf("initialize: %w", err)
+	}
+	m.serverInfo, m.capabilities = result.ServerInfo, result.Capabilities
+	return m.notify(ctx, "notifications/initialized", nil)
+}
+
+// receive handles one message from the transport. It never blocks on the
+// callers or the notification handler.
+func (m *rpcMux) receive(data []byte) {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		var batch []json.RawMessage
+		if json.Unmarshal(data, &batch) == nil {
+			for _, item := range batch {
+				m.receive(item)
+			}
+		}
+		return
+	}
+
+	var msg rpcMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return
+	}
+	switch {
+	case msg.Method != "" && len(msg.ID) > 0:
+		go m.answerServer(&msg)
+	case msg.Method != "":
+		m.queueMu.Lock()
+		m.queue = append(m.queue, &msg)
+		m.queueMu.Unlock()
+		select {
+		case m.wake <- struct{}{}:
+		default:
+		}
+	default:
+		id, err := strconv.ParseInt(string(bytes.Trim(msg.ID, `"`)), 10, 64)
+		if err != nil {
+			return
+		}
+		m.mu.Lock()
+		ch := m.pending[id]
+		delete(m.pending, id)
+		m.mu.Unlock()
+		if ch != nil {
+			ch <- &msg // buffered, so this never blocks
+		}
+	}
+}
+
+// dispatch delivers queued notifications in arrival order.
+func (m *rpcMux) dispatch() {
+	for {
+		select {
+		case <-m.wake:
+		case <-m.done:
+			return
+		}
+		for {
+			m.queueMu.Lock()
+			if len(m.queue) == 0 {
+				m.queueMu.Unlock()
+				break
+			}
+			msg := m.queue[0]
+			m.queue[0] = nil
+			m.queue = m.queue[1:]
+			m.queueMu.Unlock()
+
+			if m.onNotify != nil {
+				m.onNotify(context.Background(), msg.Method, msg.Params)
+			}
+		}
+	}
+}
+
+// answerServer replies to a request from the server. Only ping is
+// supported; the client declares no other capabilities.
+func (m *rpcMux) answerServer(req *rpcMessage) {
+	resp := rpcMessage{JSONRPC: "2.0", ID: req.ID}
+	if req.Method == "ping" {
+		resp.Result = json.RawMessage("{}")
+	} else {
+		resp.Error = &rpcError{Code: -32601, Message: "method not found: " + req.Method}
+	}
+	if data, err := json.Marshal(resp); err == nil {
+		m.t.Send(context.Background(), data)
+	}
+}
+
+// call sends a request and decodes its result into out, which may be nil.
+func (m *rpcMux) call(ctx context.Context, method string, params, out interface{}) error {
+	// Wait for a free slot
+	select {
+	case m.slots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-m.done:
+		return m.closeErr()
+	}
+	defer func() { <-m.slots }()
+
+	req := rpcMessage{JSONRPC: "2.0", Method: method}
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+		req.Params = data
+	}
+
+	ch := make(chan *rpcMessage, 1)
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return m.closeErr()
+	}
+	m.nextID++
+	id := m.nextID
+	m.pending[id] = ch
+	m.mu.Unlock()
+	req.ID = json.RawMessage(strconv.FormatInt(id, 10))
+
+	data, err := json.Marshal(req)
+	if err == nil {
+		err = m.t.Send(ctx, data)
+	}
+	if err != nil {
+		m.forget(id)
+		return fmt.Errorf("send %s: %w", method, err)
+	}
+
+	var resp *rpcMessage
+	select {
+	case resp = <-ch:
+	case <-m.done:
+		// An answer may have arrived just before the end
+		select {
+		case resp = <-ch:
+		default:
+			return m.closeErr()
+		}
+	case <-ctx.Done():
+		if m.forget(id) {
+			// Tell the server to stop working on it
+			m.notify(context.Background(), "notifications/cancelled", map[string]interface{}{
+				"requestId": id,
+				"reason":    ctx.Err().Error(),
+			})
+		}
+		return ctx.Err()
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if out == nil || len(resp.Result) == 0 {
+		return nil
+	}
+	return json.Unmarshal(resp.Result, out)
+}
+
+// forget drops a pending request and reports whether it was still pending.
+func (m *rpcMux) forget(id int64) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.pending[id]
+	delete(m.pending, id)
+	return ok
+}
+
+// notify sends a notification to the server.
+func (m *rpcMux) notify(ctx context.Context, method string, params interface{}) error {
+	msg := rpcMessage{JSONRPC: "2.0", Method: method}
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+		msg.Params = data
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return m.t.Send(ctx, data)
+}
+
+// fail ends the session with err: every outstanding and later request
+// returns it. Only the first call has an effect.
+func (m *rpcMux) fail(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return
+	}
+	m.closed, m.err = true, err
+	m.pending = make(map[int64]chan *rpcMessage)
+	close(m.done)
+}
+
+// closeErr returns why the session ended.
+func (m *rpcMux) closeErr() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.err
+}
+
+// Close fails every outstanding request and closes the transport.
+func (m *rpcMux) Close() error {
+	m.fail(errMuxClosed)
+	return m.t.Close()
+}
+
+// GetServerInfo returns the server name and version from initialize.
+func (m *rpcMux) GetServerInfo() protocol.Implementation {
+	return m.serverInfo
+}
+
+// GetServerCapabilities returns the capabilities from initialize.
+func (m *rpcMux) GetServerCapabilities() protocol.ServerCapabilities {
+	return m.capabilities
+}
+
+func (m *rpcMux) Ping(ctx context.Context) error {
+	return m.call(ctx, "ping", nil, nil)
+}
+
+// ListTools returns every tool, following pagination cursors.
+func (m *rpcMux) ListTools(ctx context.Context) (*protocol.ListToolsResult, error) {
+	all := &protocol.ListToolsResult{}
+	for cursor := protocol.Cursor(""); ; {
+		var page protocol.ListToolsResult
+		if err := m.call(ctx, "tools/list", cursorParams(cursor), &page); err != nil {
+			return nil, err
+		}
+		all.Tools = append(all.Tools, page.Tools...)
+		if cursor = page.NextCursor; cursor == "" {
+			return all, nil
+		}
+	}
+}
+
+func (m *rpcMux) CallTool(ctx context.Context, request *protocol.CallToolRequest) (*protocol.CallToolResult, error) {
+	var out protocol.CallToolResult
+	if err := m.call(ctx, "tools/call", request, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListResources returns every resource, following pagination cursors.
+func (m *rpcMux) ListResources(ctx context.Context) (*protocol.ListResourcesResult, error) {
+	all := &protocol.ListResourcesResult{}
+	for cursor := protocol.Cursor(""); ; {
+		var page protocol.ListResourcesResult
+		if err := m.call(ctx, "resources/list", cursorParams(cursor), &page); err != nil {
+			return nil, err
+		}
+		all.Resources = append(all.Resources, page.Resources...)
+		if cursor = page.NextCursor; cursor == "" {
+			return all, nil
+		}
+	}
+}
+
+func (m *rpcMux) ReadResource(ctx context.Context, request *protocol.ReadResourceRequest) (*protocol.ReadResourceResult, error) {
+	var out protocol.ReadResourceResult
+	if err := m.call(ctx, "resources/read", request, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListPrompts returns every prompt, following pagination cursors.
+func (m *rpcMux) ListPrompts(ctx context.Context) (*protocol.ListPromptsResult, error) {
+	all := &protocol.ListPromptsResult{}
+	for cursor := protocol.Cursor(""); ; {
+		var page protocol.ListPromptsResult
+		if err := m.call(ctx, "prompts/list", cursorParams(cursor), &page); err != nil {
+			return nil, err
+		}
+		all.Prompts = append(all.Prompts, page.Prompts...)
+		if cursor = page.NextCursor; cursor == "" {
+			return all, nil
+		}
+	}
+}
+
+func (m *rpcMux) GetPrompt(ctx context.Context, request *protocol.GetPromptRequest) (*protocol.GetPromptResult, error) {
+	var out protocol.GetPromptResult
+	if err := m.call(ctx, "prompts/get", request, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func cursorParams(cursor protocol.Cursor) interface{} {
+	if cursor == "" {
+		return nil
+	}
+	return map[string]interface{}{"cursor": cursor}
+}
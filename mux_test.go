@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ThinkInAIXYZ/go-mcp/transport"
+)
+
+// fakeServer is an in-memory transport. It answers initialize itself and
+// hands every other request to the test, which replies in any order.
+type fakeServer struct {
+	closeHook
+	mu       sync.Mutex
+	receiver transport.ClientReceiver
+
+	requests      chan *rpcMessage
+	notifications chan *rpcMessage
+}
+
+func newFakeServer() *fakeServer {
+	return &fakeServer{
+		requests:      make(chan *rpcMessage, 100),
+		notifications: make(chan *rpcMessage, 100),
+	}
+}
+
+func (f *fakeServer) Start() error { return nil }
+func (f *fakeServer) Close() error { return nil }
+
+func (f *fakeServer) SetReceiver(r transport.ClientReceiver) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.receiver = r
+}
+
+func (f *fakeServer) Send(ctx context.Context, msg transport.Message) error {
+	var m rpcMessage
+	if err := json.Unmarshal(msg, &m); err != nil {
+		return err
+	}
+	switch {
+	case m.Method == "initialize":
+		go f.reply(&m, map[string]interface{}{"serverInfo": map[string]string{"name": "fake", "version": "1"}})
+	case len(m.ID) == 0:
+		f.notifications <- &m
+	default:
+		f.requests <- &m
+	}
+	return nil
+}
+
+// deliver passes a raw message to the client.
+func (f *fakeServer) deliver(v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	f.mu.Lock()
+	r := f.receiver
+	f.mu.Unlock()
+	r.Receive(context.Background(), data)
+}
+
+func (f *fakeServer) reply(req *rpcMessage, result interface{}) {
+	data, _ := json.Marshal(result)
+	f.deliver(rpcMessage{JSONRPC: "2.0", ID: req.ID, Result: data})
+}
+
+// drop ends the connection from the server's side.
+func (f *fakeServer) drop(err error) {
+	f.fire(err)
+}
+
+func (f *fakeServer) notify(method string) {
+	f.deliver(rpcMessage{JSONRPC: "2.0", Method: method})
+}
+
+// next returns the next request from the client, failing after a timeout.
+func (f *fakeServer) next(t *testing.T) *rpcMessage {
+	t.Helper()
+	select {
+	case m := <-f.requests:
+		return m
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a request")
+		return nil
+	}
+}
+
+func newTestMux(t *testing.T, maxInFlight int, onNotify notificationHandler) (*rpcMux, *fakeServer) {
+	t.Helper()
+	f := newFakeServer()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	m, err := newRPCMux(ctx, f, maxInFlight, onNotify)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { m.Close() })
+	if n := <-f.notifications; n.Method != "notifications/initialized" {
+		t.Fatalf("got %s after initialize, want notifications/initialized", n.Method)
+	}
+	return m, f
+}
+
+func TestMuxOutOfOrderResponses(t *testing.T) {
+	const callers = 20
+	m, f := newTestMux(t, callers, nil)
+	if got := m.GetServerInfo().Name; got != "fake" {
+		t.Errorf("server name %q, want fake", got)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var out struct{ N int }
+			if err := m.call(context.Background(), "echo", map[string]int{"n": i}, &out); err != nil {
+				errs <- err
+				return
+			}
+			if out.N != i {
+				errs <- fmt.Errorf("caller %d got the answer for %d", i, out.N)
+			}
+		}(i)
+	}
+
+	// Collect every request, then answer them newest first
+	reqs := make([]*rpcMessage, callers)
+	for i := range reqs {
+		reqs[i] = f.next(t)
+	}
+	for i := len(reqs) - 1; i >= 0; i-- {
+		var params struct{ N int }
+		json.Unmarshal(reqs[i].Params, &params)
+		go f.reply(reqs[i], map[string]int{"n": params.N})
+	}
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestMuxBackPressure(t *testing.T) {
+	m, f := newTestMux(t, 2, nil)
+
+	done := make(chan struct{}, 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			m.call(context.Background(), "slow", nil, nil)
+			done <- struct{}{}
+		}()
+	}
+
+	first := f.next(t)
+	f.next(t)
+	select {
+	case <-f.requests:
+		t.Fatal("third request sent while two were outstanding")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	f.reply(first, struct{}{})
+	third := f.next(t)
+	f.reply(third, struct{}{})
+	<-done
+	<-done
+}
+
+func TestMuxNotificationOrder(t *testing.T) {
+	const count = 50
+	var (
+		mu   sync.Mutex
+		got  []string
+		gate = make(chan struct{})
+		all  = make(chan struct{})
+	)
+	m, f := newTestMux(t, 0, func(ctx context.Context, method string, _ json.RawMessage) {
+		if method == "n/0" {
+			<-gate // hold up delivery of the rest
+		}
+		mu.Lock()
+		got = append(got, method)
+		if len(got) == count {
+			close(all)
+		}
+		mu.Unlock()
+	})
+
+	for i := 0; i < count; i++ {
+		f.notify(fmt.Sprintf("n/%d", i))
+	}
+
+	// A blocked handler must not hold up responses
+	result := make(chan error, 1)
+	go func() { result <- m.call(context.Background(), "ping", nil, nil) }()
+	f.reply(f.next(t), struct{}{})
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("response waited for the notification handler")
+	}
+
+	close(gate)
+	select {
+	case <-all:
+	case <-time.After(5 * time.Second):
+		t.Fatal("notifications were not all delivered")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for i, method := range got {
+		if want := fmt.Sprintf("n/%d", i); method != want {
+			t.Fatalf("notification %d was %s, want %s", i, method, want)
+		}
+	}
+}
+
+func TestMuxCancel(t *testing.T) {
+	m, f := newTestMux(t, 1, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+	go func() { result <- m.call(ctx, "slow", nil, nil) }()
+	req := f.next(t)
+	cancel()
+	if err := <-result; !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+	if n := <-f.notifications; n.Method != "notifications/cancelled" {
+		t.Fatalf("got %s, want notifications/cancelled", n.Method)
+	}
+
+	// A late answer is dropped and the slot is free again
+	f.reply(req, struct{}{})
+	go func() { result <- m.call(context.Background(), "next", nil, nil) }()
+	f.reply(f.next(t), struct{}{})
+	if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMuxCloseFailsPending(t *testing.T) {
+	m, f := newTestMux(t, 0, nil)
+
+	result := make(chan error, 1)
+	go func() { result <- m.call(context.Background(), "slow", nil, nil) }()
+	f.next(t)
+	m.Close()
+	if err := <-result; err == nil {
+		t.Fatal("pending call succeeded after Close")
+	}
+	if err := m.call(context.Background(), "after", nil, nil); !errors.Is(err, errMuxClosed) {
+		t.Fatalf("got %v, want errMuxClosed", err)
+	}
+}
+
+func TestMuxTransportLostFailsPending(t *testing.T) {
+	m, f := newTestMux(t, 0, nil)
+
+	result := make(chan error, 1)
+	go func() { result <- m.call(context.Background(), "slow", nil, nil) }()
+	f.next(t)
+	f.drop(errors.New("connection reset by peer"))
+	select {
+	case err := <-result:
+		if !errors.Is(err, errMuxClosed) {
+			t.Fatalf("got %v, want errMuxClosed", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("pending call still waiting after the transport was lost")
+	}
+	if err := m.call(context.Background(), "after", nil, nil); !errors.Is(err, errMuxClosed) {
+		t.Fatalf("got %v, want errMuxClosed", err)
+	}
+}
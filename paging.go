@@ -0,0 +1,336 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// pageQuery wraps a query so it returns rows offset+1 to offset+limit.
+// orderBy is the ordering the pages are cut from; it must be unique for
+// the pages not to overlap.
+type pageQuery func(query, orderBy string, offset, limit int) string
+
+// sqlDialects are the paging styles accepted by the sql config's dialect
+// setting and the -dialect flag.
+var sqlDialects = map[string]pageQuery{
+	// Teradata has no OFFSET, so rows are numbered and filtered instead
+	"teradata": func(query, orderBy string, offset, limit int) string {
+		return fmt.Sprintf("SELECT * FROM (%s) AS paged_query QUALIFY ROW_NUMBER() OVER (ORDER BY %s) BETWEEN %d AND %d ORDER BY %s",
+			query, orderBy, offset+1, offset+limit, orderBy)
+	},
+	// PostgreSQL, MySQL, SQLite and others
+	"limit": func(query, orderBy string, offset, limit int) string {
+		return fmt.Sprintf("SELECT * FROM (%s) AS paged_query ORDER BY %s LIMIT %d OFFSET %d",
+			query, orderBy, limit, offset)
+	},
+	// SQL:2008, as in SQL Server 2012+, Oracle 12c+ and Db2
+	"ansi": func(query, orderBy string, offset, limit int) string {
+		return fmt.Sprintf("SELECT * FROM (%s) AS paged_query ORDER BY %s OFFSET %d ROWS FETCH NEXT %d ROWS ONLY",
+			query, orderBy, offset, limit)
+	},
+}
+
+// splitOrderBy removes a trailing top-level ORDER BY clause from query and
+// returns it separately, since most databases reject ORDER BY in a derived
+// table. A trailing semicolon is dropped as well.
+func splitOrderBy(query string) (body, orderBy string) {
+	query = strings.TrimRight(strings.TrimSpace(query), "; \t\n")
+	runes := []rune(query)
+	depth, cut, clause := 0, -1, -1
+	prev, prevStart := "", 0
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '-' && i+1 < len(runes) && runes[i+1] == '-':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			for i += 2; i+1 < len(runes) && !(runes[i] == '*' && runes[i+1] == '/'); i++ {
+			}
+			i++
+		case r == '\'' || r == '"':
+			for i++; i < len(runes); i++ {
+				if runes[i] == r {
+					if i+1 < len(runes) && runes[i+1] == r {
+						i++
+						continue
+					}
+					break
+				}
+			}
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+		case isSQLWordRune(r):
+			start := i
+			for i+1 < len(runes) && isSQLWordRune(runes[i+1]) {
+				i++
+			}
+			word := strings.ToUpper(string(runes[start : i+1]))
+			if depth == 0 && prev == "ORDER" && word == "BY" {
+				cut, clause = prevStart, i+1
+			}
+			prev, prevStart = word, start
+		}
+	}
+	if cut < 0 {
+		return query, ""
+	}
+	return strings.TrimSpace(string(runes[:cut])), strings.TrimSpace(string(runes[clause:]))
+}
+
+// outerOrderBy rewrites a query's own ORDER BY to sort the derived table
+// the pages are cut from, where only the query's output columns are
+// visible: qualified names lose their qualifier and plain names are kept.
+// Ordinals become the names columnName returns for them, since inside a
+// window's ORDER BY they would be constants. Other expressions cannot be
+// rewritten.
+func outerOrderBy(orderBy string, columnName func(n int) (string, error)) (string, error) {
+	var keys []string
+	for _, item := range splitTopLevel(orderBy) {
+		toks := sqlTokens(item)
+		n := len(toks)
+		for n > 0 && isSortModifier(toks[n-1]) {
+			n--
+		}
+		expr := ""
+		switch {
+		case n == 1 && !strings.HasPrefix(toks[0], "'"):
+			expr = toks[0]
+		case n >= 3 && n%2 == 1:
+			// a.b or db.t.c
+			expr = toks[n-1]
+			for i := 1; i < n; i += 2 {
+				if toks[i] != "." {
+					expr = ""
+				}
+			}
+		}
+		if expr == "" || !(isSQLWordRune([]rune(expr)[0]) || expr[0] == '"' || expr[0] == '`') {
+			return "", fmt.Errorf("cannot page by ORDER BY %s, which is not an output column; pass -order-by with output column names", strings.TrimSpace(item))
+		}
+		if n, err := strconv.Atoi(expr); err == nil {
+			name, err := columnName(n)
+			if err != nil {
+				return "", fmt.Errorf("cannot page by ORDER BY %s: %v; pass -order-by with output column names", strings.TrimSpace(item), err)
+			}
+			expr = quoteSQLName(name)
+		}
+		keys = append(keys, strings.Join(append([]string{expr}, toks[n:]...), " "))
+	}
+	return strings.Join(keys, ", "), nil
+}
+
+// quoteSQLName returns name as it must be written in a statement: as is
+// when it is a plain identifier, double-quoted otherwise.
+func quoteSQLName(name string) string {
+	plain := name != "" && !unicode.IsDigit([]rune(name)[0])
+	for _, r := range name {
+		if !isSQLWordRune(r) {
+			plain = false
+		}
+	}
+	if plain {
+		return name
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func isSortModifier(tok string) bool {
+	switch strings.ToUpper(tok) {
+	case "ASC", "DESC", "NULLS", "FIRST", "LAST":
+		return true
+	}
+	return false
+}
+
+// splitTopLevel splits s at commas outside parentheses and quotes.
+func splitTopLevel(s string) []string {
+	var (
+		parts []string
+		depth int
+		quote rune
+		start int
+	)
+	for i, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+		case r == ',' && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// sqlTokens splits s into words, quoted names and literals, and single
+// other characters, dropping spaces.
+func sqlTokens(s string) []string {
+	var toks []string
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		start := i
+		switch {
+		case unicode.IsSpace(r):
+			continue
+		case r == '\'' || r == '"' || r == '`':
+			for i++; i < len(runes) && runes[i] != r; i++ {
+			}
+		case isSQLWordRune(r):
+			for i+1 < len(runes) && isSQLWordRune(runes[i+1]) {
+				i++
+			}
+		}
+		if i >= len(runes) {
+			i = len(runes) - 1
+		}
+		toks = append(toks, string(runes[start:i+1]))
+	}
+	return toks
+}
+
+// pager fetches a query's rows page by page through the query tool.
+type pager struct {
+	sess     *session
+	tool     string
+	argument string
+	wrap     pageQuery
+	pageSize int
+	maxRows  int // zero means no cap
+}
+
+// run streams every page of query to rw and returns the number of rows
+// written. When orderBy is empty the query's own ORDER BY is used, and
+// failing that the first column of the result.
+func (p *pager) run(ctx context.Context, query, orderBy string, rw rowWriter) (int, error) {
+	body, own := splitOrderBy(query)
+	if orderBy == "" && own != "" {
+		var columns []column
+		columnName := func(n int) (string, error) {
+			if columns == nil {
+				var err error
+				if columns, err = p.columns(ctx, body); err != nil {
+					return "", err
+				}
+			}
+			if n < 1 || n > len(columns) {
+				return "", fmt.Errorf("the query has %d columns", len(columns))
+			}
+			return columns[n-1].Name, nil
+		}
+		var err error
+		if orderBy, err = outerOrderBy(own, columnName); err != nil {
+			return 0, err
+		}
+	}
+	if orderBy == "" {
+		first, err := p.firstColumn(ctx, body)
+		if err != nil {
+			return 0, err
+		}
+		log.Printf("Paging ordered by %s; pass -order-by with a unique key if pages overlap", first)
+		orderBy = first
+	}
+
+	var header []column
+	total := 0
+	for page := 1; ; page++ {
+		limit := p.pageSize
+		if p.maxRows > 0 && p.maxRows-total < limit {
+			limit = p.maxRows - total
+		}
+		if limit <= 0 {
+			log.Printf("Stopped at the cap of %d rows", p.maxRows)
+			return total, nil
+		}
+
+		t, err := p.fetch(ctx, p.wrap(body, orderBy, total, limit))
+		if err != nil {
+			return total, fmt.Errorf("page %d: %w", page, err)
+		}
+		if header == nil {
+			header = t.Columns
+			if err := rw.WriteHeader(header); err != nil {
+				return total, err
+			}
+		} else if !sameColumns(header, t.Columns) && len(t.Rows) > 0 {
+			return total, fmt.Errorf("page %d: columns changed between pages", page)
+		}
+		if err := rw.WriteRows(t.Rows); err != nil {
+			return total, err
+		}
+		total += len(t.Rows)
+		log.Printf("Page %d: %d rows (%d total)", page, len(t.Rows), total)
+
+		if len(t.Rows) < limit {
+			return total, nil
+		}
+	}
+}
+
+// firstColumn returns the name of the first column query returns.
+func (p *pager) firstColumn(ctx context.Context, query string) (string, error) {
+	columns, err := p.columns(ctx, query)
+	if err != nil {
+		return "", fmt.Errorf("find a column to order pages by: %w", err)
+	}
+	if len(columns) == 0 {
+		return "", errors.New("find a column to order pages by: query returned no columns; use -order-by")
+	}
+	return quoteSQLName(columns[0].Name), nil
+}
+
+// columns returns the columns query returns, by fetching a single row.
+func (p *pager) columns(ctx context.Context, query string) ([]column, error) {
+	t, err := p.fetch(ctx, p.wrap(query, "1", 0, 1))
+	if err != nil {
+		return nil, err
+	}
+	return t.Columns, nil
+}
+
+// fetch runs one statement and returns its rows. A result without row
+// data is an empty page.
+func (p *pager) fetch(ctx context.Context, sql string) (*table, error) {
+	result, err := p.sess.CallTool(ctx, p.tool, map[string]interface{}{p.argument: sql})
+	if err != nil {
+		return nil, err
+	}
+	if result.IsError {
+		return nil, fmt.Errorf("query failed: %s", abbreviate(resultText(result), 200))
+	}
+	t, ok := resultTable(result)
+	if !ok {
+		return &table{}, nil
+	}
+	return t, nil
+}
+
+func sameColumns(a, b []column) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Name != b[i].Name {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSplitOrderBy(t *testing.T) {
+	tests := []struct {
+		query, body, orderBy string
+	}{
+		{"SELECT a FROM t", "SELECT a FROM t", ""},
+		{"SELECT a FROM t ORDER BY a;", "SELECT a FROM t", "a"},
+		{"SELECT a FROM t\nORDER BY t.a DESC, 2", "SELECT a FROM t", "t.a DESC, 2"},
+		{"SELECT a FROM (SELECT a FROM t ORDER BY a) x", "SELECT a FROM (SELECT a FROM t ORDER BY a) x", ""},
+		{"SELECT 'ORDER BY a' FROM t", "SELECT 'ORDER BY a' FROM t", ""},
+		{"SELECT a FROM t -- ORDER BY a", "SELECT a FROM t -- ORDER BY a", ""},
+	}
+	for _, tt := range tests {
+		body, orderBy := splitOrderBy(tt.query)
+		if body != tt.body || orderBy != tt.orderBy {
+			t.Errorf("%q: got (%q, %q), want (%q, %q)", tt.query, body, orderBy, tt.body, tt.orderBy)
+		}
+	}
+}
+
+func TestOuterOrderBy(t *testing.T) {
+	tests := []struct {
+		orderBy string
+		want    string // "" when refused
+	}{
+		{"a", "a"},
+		{"a DESC", "a DESC"},
+		{"t.a, u.b DESC NULLS LAST", "a, b DESC NULLS LAST"},
+		{"db.t.a ASC", "a ASC"},
+		{`t."Order Date" DESC`, `"Order Date" DESC`},
+		{"2, 1 DESC", `"Order Date", a DESC`},
+		{"3", ""},
+		{"a + b", ""},
+		{"UPPER(t.name)", ""},
+		{"CASE WHEN a THEN 1 END", ""},
+		{"'x'", ""},
+	}
+	for _, tt := range tests {
+		got, err := outerOrderBy(tt.orderBy, func(n int) (string, error) {
+			columns := []string{"a", "Order Date"}
+			if n > len(columns) {
+				return "", errors.New("the query has 2 columns")
+			}
+			return columns[n-1], nil
+		})
+		switch {
+		case tt.want == "" && err == nil:
+			t.Errorf("%q: got %q, want an error", tt.orderBy, got)
+		case tt.want == "" && !strings.Contains(err.Error(), "-order-by"):
+			t.Errorf("%q: error %q does not mention -order-by", tt.orderBy, err)
+		case tt.want != "" && (err != nil || got != tt.want):
+			t.Errorf("%q: got (%q, %v), want %q", tt.orderBy, got, err, tt.want)
+		}
+	}
+}
+
+func TestPagingDialects(t *testing.T) {
+	got := sqlDialects["teradata"]("SELECT a FROM t", "a", 100, 50)
+	want := "SELECT * FROM (SELECT a FROM t) AS paged_query QUALIFY ROW_NUMBER() OVER (ORDER BY a) BETWEEN 101 AND 150 ORDER BY a"
+	if got != want {
+		t.Errorf("teradata: got %q, want %q", got, want)
+	}
+	got = sqlDialects["limit"]("SELECT a FROM t", "a", 100, 50)
+	if want := "SELECT * FROM (SELECT a FROM t) AS paged_query ORDER BY a LIMIT 50 OFFSET 100"; got != want {
+		t.Errorf("limit: got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/ThinkInAIXYZ/go-mcp/protocol"
+)
+
+// policyConfig controls which upstream tools are exposed and under what
+// name. All rules are keyed by the upstream tool name.
+//
+//	{
+//	  "allow": ["*"],
+//	  "deny": ["drop_*", "query"],
+//	  "annotations": {"readOnlyHint": true},
+//	  "rename": {"list_distinct_values": "distinct"},
+//	  "descriptions": {"standard_deviation": "Column standard deviation"},
+//	  "confirm": ["query"]
+//	}
+type policyConfig struct {
+	// Allow lists names or globs of tools to expose. Empty means all.
+	Allow []string `json:"allow,omitempty"`
+	// Deny lists names or globs of tools to hide. Deny wins over Allow.
+	Deny []string `json:"deny,omitempty"`
+	// Annotations requires each listed hint to have the given value.
+	// Hints the server omits take their MCP default.
+	Annotations map[string]bool `json:"annotations,omitempty"`
+	// Rename maps upstream names to the names shown to the user.
+	Rename map[string]string `json:"rename,omitempty"`
+	// Descriptions overrides the description of upstream tools.
+	Descriptions map[string]string `json:"descriptions,omitempty"`
+	// Confirm lists names or globs of tools that always need confirmation
+	// before they are called, whatever their annotations say.
+	Confirm []string `json:"confirm,omitempty"`
+}
+
+// toolPolicy applies a policyConfig to tool listings and tool calls.
+type toolPolicy struct {
+	cfg     policyConfig
+	exposed map[string]string // exposed name -> upstream name
+}
+
+func newToolPolicy(cfg policyConfig) (*toolPolicy, error) {
+	patterns := append(append(append([]string{}, cfg.Allow...), cfg.Deny...), cfg.Confirm...)
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("policy: bad pattern %q: %w", pattern, err)
+		}
+	}
+	for hint := range cfg.Annotations {
+		if _, ok := annotationDefaults[hint]; !ok {
+			return nil, fmt.Errorf("policy: unknown annotation %q", hint)
+		}
+	}
+
+	p := &toolPolicy{cfg: cfg, exposed: make(map[string]string)}
+	for upstream, name := range cfg.Rename {
+		if other, ok := p.exposed[name]; ok {
+			return nil, fmt.Errorf("policy: %q and %q are both renamed to %q", other, upstream, name)
+		}
+		p.exposed[name] = upstream
+	}
+	return p, nil
+}
+
+// annotationDefaults holds the values the MCP spec assumes for hints that
+// a server does not send.
+var annotationDefaults = map[string]bool{
+	"readOnlyHint":    false,
+	"destructiveHint": true,
+	"idempotentHint":  false,
+	"openWorldHint":   true,
+}
+
+// annotationHint returns the value of a tool annotation hint, falling back
+// to the MCP default when the server did not set it.
+func annotationHint(tool *protocol.Tool, hint string) bool {
+	var value *bool
+	if a := tool.Annotations; a != nil {
+		switch hint {
+		case "readOnlyHint":
+			value = a.ReadOnlyHint
+		case "destructiveHint":
+			value = a.DestructiveHint
+		case "idempotentHint":
+			value = a.IdempotentHint
+		case "openWorldHint":
+			value = a.OpenWorldHint
+		}
+	}
+	if value == nil {
+		return annotationDefaults[hint]
+	}
+	return *value
+}
+
+// matchAny reports whether name matches any of the glob patterns.
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// permitsName applies the allow and deny lists to an upstream name.
+func (p *toolPolicy) permitsName(upstream string) bool {
+	if len(p.cfg.Allow) > 0 && !matchAny(p.cfg.Allow, upstream) {
+		return false
+	}
+	return !matchAny(p.cfg.Deny, upstream)
+}
+
+// permits reports whether an upstream tool is visible under the policy.
+func (p *toolPolicy) permits(tool *protocol.Tool) bool {
+	if !p.permitsName(tool.Name) {
+		return false
+	}
+	for hint, want := range p.cfg.Annotations {
+		if annotationHint(tool, hint) != want {
+			return false
+		}
+	}
+	return true
+}
+
+// exposedName returns the name a tool is shown under.
+func (p *toolPolicy) exposedName(upstream string) string {
+	if name, ok := p.cfg.Rename[upstream]; ok {
+		return name
+	}
+	return upstream
+}
+
+// checkNames rejects a catalog in which two visible tools would be shown
+// under one name, such as a tool renamed to the name of another tool that
+// keeps its own. A call to that name could reach either.
+func (p *toolPolicy) checkNames(tools []*protocol.Tool) error {
+	seen := make(map[string]string) // exposed name -> upstream name
+	for _, tool := range tools {
+		if !p.permits(tool) {
+			continue
+		}
+		name := p.exposedName(tool.Name)
+		if other, ok := seen[name]; ok && other != tool.Name {
+			return fmt.Errorf("policy: %q and %q are both exposed as %q; rename or deny one of them", other, tool.Name, name)
+		}
+		seen[name] = tool.Name
+	}
+	return nil
+}
+
+// apply filters and rewrites an upstream catalog. The input tools are not
+// modified.
+func (p *toolPolicy) apply(tools []*protocol.Tool) []*protocol.Tool {
+	var out []*protocol.Tool
+	for _, tool := range tools {
+		if !p.permits(tool) {
+			continue
+		}
+		t := *tool
+		t.Name = p.exposedName(tool.Name)
+		if desc, ok := p.cfg.Descriptions[tool.Name]; ok {
+			t.Description = desc
+		}
+		out = append(out, &t)
+	}
+	return out
+}
+
+// resolve maps a name the user called to the upstream tool, rejecting
+// tools the policy hides. A tool that was renamed is not reachable under
+// its upstream name.
+func (p *toolPolicy) resolve(name string, catalog []*protocol.Tool) (*protocol.Tool, error) {
+	if err := p.checkNames(catalog); err != nil {
+		return nil, err
+	}
+	upstream, renamed := p.exposed[name]
+	if !renamed {
+		if _, ok := p.cfg.Rename[name]; ok {
+			return nil, fmt.Errorf("tool %q is not available", name)
+		}
+		upstream = name
+	}
+
+	for _, tool := range catalog {
+		if tool.Name != upstream {
+			continue
+		}
+		if !p.permits(tool) {
+			return nil, fmt.Errorf("tool %q is not available", name)
+		}
+		return tool, nil
+	}
+	return nil, fmt.Errorf("tool %q is not available", name)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ThinkInAIXYZ/go-mcp/protocol"
+)
+
+func TestPolicyRenameCollisions(t *testing.T) {
+	catalog := []*protocol.Tool{{Name: "query"}, {Name: "sql"}, {Name: "drop_table"}}
+	tests := []struct {
+		name    string
+		cfg     policyConfig
+		collide string // part of the error, or "" when the names are unique
+	}{
+		{"no renames", policyConfig{}, ""},
+		{"rename to a free name", policyConfig{Rename: map[string]string{"query": "run"}}, ""},
+		{"swap", policyConfig{Rename: map[string]string{"query": "sql", "sql": "query"}}, ""},
+		{"rename onto a renamed tool", policyConfig{Rename: map[string]string{"query": "sql", "sql": "raw_sql"}}, ""},
+		{"rename onto a hidden tool", policyConfig{Rename: map[string]string{"query": "drop_table"}, Deny: []string{"drop_*"}}, ""},
+		{"rename onto a visible tool", policyConfig{Rename: map[string]string{"query": "sql"}}, `exposed as "sql"`},
+		{"rename onto an unlisted tool", policyConfig{Rename: map[string]string{"drop_table": "query"}}, `exposed as "query"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := newToolPolicy(tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = p.checkNames(catalog)
+			_, resolveErr := p.resolve("query", catalog)
+			switch {
+			case tt.collide == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.collide != "" && (err == nil || !strings.Contains(err.Error(), tt.collide)):
+				t.Errorf("got %v, want an error with %q", err, tt.collide)
+			case tt.collide != "" && resolveErr == nil:
+				t.Error("resolve reached a tool under a colliding name")
+			}
+		})
+	}
+}
+
+func TestPolicyDuplicateRenameTargets(t *testing.T) {
+	_, err := newToolPolicy(policyConfig{Rename: map[string]string{"a": "x", "b": "x"}})
+	if err == nil {
+		t.Fatal("two tools renamed to one name were accepted")
+	}
+}
@@ -0,0 +1,486 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"os"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// profileConfig maps the profile command onto a server's tools. The
+// defaults fit the Teradata MCP server, with the query tool taken from the
+// sql section; every string is a text/template
+// over .database, .table (qualified name), .tableName, .column and
+// .pattern, with the workflow template functions available.
+//
+//	"profile": {
+//	  "queryTool": "query",
+//	  "tableTools": [{"title": "Negative values", "tool": "list_negative_values",
+//	                  "arguments": {"table_name": "{{ .table }}"}}],
+//	  "columnTools": [{"title": "Standard deviation", "tool": "standard_deviation", "numericOnly": true,
+//	                   "arguments": {"table_name": "{{ .table }}", "column_name": "{{ .column }}"}}]
+//	}
+type profileConfig struct {
+	QueryTool     string        `json:"queryTool,omitempty"`
+	QueryArgument string        `json:"queryArgument,omitempty"`
+	TablesQuery   string        `json:"tablesQuery,omitempty"`
+	ColumnsQuery  string        `json:"columnsQuery,omitempty"`
+	NumericTypes  []string      `json:"numericTypes,omitempty"`
+	TableTools    []profileTool `json:"tableTools,omitempty"`
+	ColumnTools   []profileTool `json:"columnTools,omitempty"`
+}
+
+// profileTool is one tool call made per table or per column.
+type profileTool struct {
+	Title     string            `json:"title"`
+	Tool      string            `json:"tool"`
+	Arguments map[string]string `json:"arguments"`
+	// NumericOnly limits a column tool to numeric columns.
+	NumericOnly bool `json:"numericOnly,omitempty"`
+}
+
+// defaultProfileConfig profiles through the Teradata MCP server tools and
+// the dbc dictionary views.
+func defaultProfileConfig() profileConfig {
+	return profileConfig{
+		QueryTool:     "query",
+		QueryArgument: "query",
+		TablesQuery: "SELECT DatabaseName, TableName FROM dbc.TablesV " +
+			"WHERE DatabaseName LIKE {{ sqlquote .database }} AND TableName LIKE {{ sqlquote .pattern }} " +
+			"AND TableKind IN ('T', 'O', 'V') ORDER BY DatabaseName, TableName",
+		ColumnsQuery: "SELECT ColumnName, ColumnType FROM dbc.ColumnsV " +
+			"WHERE DatabaseName = {{ sqlquote .database }} AND TableName = {{ sqlquote .tableName }} " +
+			"ORDER BY ColumnId",
+		NumericTypes: []string{"I", "I1", "I2", "I8", "F", "D", "N"},
+		TableTools: []profileTool{
+			{Title: "Negative values", Tool: "list_negative_values", Arguments: map[string]string{"table_name": "{{ .table }}"}},
+			{Title: "Distinct values", Tool: "list_distinct_values", Arguments: map[string]string{"table_name": "{{ .table }}"}},
+		},
+		ColumnTools: []profileTool{
+			{Title: "Standard deviation", Tool: "standard_deviation", NumericOnly: true,
+				Arguments: map[string]string{"table_name": "{{ .table }}", "column_name": "{{ .column }}"}},
+		},
+	}
+}
+
+// merge fills the fields cfg leaves empty from the defaults.
+func (cfg profileConfig) merge(def profileConfig) profileConfig {
+	if cfg.QueryTool == "" {
+		cfg.QueryTool = def.QueryTool
+	}
+	if cfg.QueryArgument == "" {
+		cfg.QueryArgument = def.QueryArgument
+	}
+	if cfg.TablesQuery == "" {
+		cfg.TablesQuery = def.TablesQuery
+	}
+	if cfg.ColumnsQuery == "" {
+		cfg.ColumnsQuery = def.ColumnsQuery
+	}
+	if cfg.NumericTypes == nil {
+		cfg.NumericTypes = def.NumericTypes
+	}
+	if cfg.TableTools == nil {
+		cfg.TableTools = def.TableTools
+	}
+	if cfg.ColumnTools == nil {
+		cfg.ColumnTools = def.ColumnTools
+	}
+	return cfg
+}
+
+// profileReport is the assembled output of the profile command.
+type profileReport struct {
+	Server    string         `json:"server"`
+	Generated time.Time      `json:"generated"`
+	Tables    []tableProfile `json:"tables"`
+}
+
+type tableProfile struct {
+	Table    string           `json:"table"`
+	Error    string           `json:"error,omitempty"`
+	Sections []profileSection `json:"sections"`
+}
+
+// profileSection is the result of one tool call.
+type profileSection struct {
+	Title   string                   `json:"title"`
+	Tool    string                   `json:"tool"`
+	Column  string                   `json:"column,omitempty"`
+	Error   string                   `json:"error,omitempty"`
+	Text    string                   `json:"text,omitempty"`
+	Columns []string                 `json:"columns,omitempty"`
+	Rows    []map[string]interface{} `json:"rows,omitempty"`
+
+	table *table
+}
+
+// profileColumn is a column of a profiled table.
+type profileColumn struct {
+	Name    string
+	Numeric bool
+}
+
+// profiler runs the profiling calls over one session.
+type profiler struct {
+	sess *session
+	cfg  profileConfig
+}
+
+// runProfileCommand implements "profile [flags] TABLE...". A table given
+// as database.table is profiled directly; one containing % or * is a
+// pattern expanded through the query tool.
+func runProfileCommand(a *app, url string, args []string) error {
+	fs := flag.NewFlagSet("profile", flag.ExitOnError)
+	format := fs.String("format", "markdown", "Report format: markdown, html or json")
+	outPath := fs.String("out", "", "Write the report to this file instead of stdout")
+	noColumns := fs.Bool("no-columns", false, "Skip per-column tools")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: profile [flags] DATABASE.TABLE|DATABASE.PATTERN...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("profile: expected at least one table")
+	}
+	switch *format {
+	case "markdown", "md", "html", "json":
+	default:
+		return fmt.Errorf("profile: unknown format %q", *format)
+	}
+
+	sess, err := a.connect(url)
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	def := defaultProfileConfig()
+	sqlCfg := a.cfg.SQL.withDefaults()
+	def.QueryTool, def.QueryArgument = sqlCfg.Tool, sqlCfg.Argument
+	p := &profiler{sess: sess, cfg: a.cfg.Profile.merge(def)}
+	if *noColumns {
+		p.cfg.ColumnTools = []profileTool{}
+	}
+	ctx := context.Background()
+
+	// Expand patterns into tables
+	var tables []string
+	for _, arg := range fs.Args() {
+		if !strings.ContainsAny(arg, "%*") {
+			tables = append(tables, arg)
+			continue
+		}
+		found, err := p.expand(ctx, arg)
+		if err != nil {
+			return fmt.Errorf("profile: expand %s: %w", arg, err)
+		}
+		if len(found) == 0 {
+			log.Printf("No tables match %s", arg)
+		}
+		tables = append(tables, found...)
+	}
+
+	report := &profileReport{Server: url, Generated: time.Now().UTC()}
+	for _, t := range tables {
+		log.Printf("Profiling %s", t)
+		report.Tables = append(report.Tables, p.profile(ctx, t))
+	}
+
+	var w io.Writer = os.Stdout
+	if *outPath != "" {
+		f, err := os.Create(*outPath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	switch *format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	case "html":
+		return writeProfileHTML(w, report)
+	}
+	return writeProfileMarkdown(w, report)
+}
+
+// splitTable splits "db.table" into its parts. A bare name has no database.
+func splitTable(name string) (database, table string) {
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		return name[:i], name[i+1:]
+	}
+	return "", name
+}
+
+// templateData is the context for the profile config templates.
+func templateData(table, column, pattern string) map[string]interface{} {
+	database, tableName := splitTable(table)
+	if pattern != "" {
+		database, pattern = splitTable(pattern)
+		pattern = strings.ReplaceAll(pattern, "*", "%")
+		database = strings.ReplaceAll(database, "*", "%")
+		if database == "" {
+			database = "%"
+		}
+	}
+	return map[string]interface{}{
+		"database":  database,
+		"table":     table,
+		"tableName": tableName,
+		"column":    column,
+		"pattern":   pattern,
+	}
+}
+
+// renderProfileTemplate executes one config template.
+func renderProfileTemplate(text string, data map[string]interface{}) (string, error) {
+	tmpl, err := texttemplate.New("").Funcs(workflowFuncs).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// query runs SQL through the configured query tool and returns its rows.
+// A result without row data, such as a message from the server, is an
+// error.
+func (p *profiler) query(ctx context.Context, text string, data map[string]interface{}) (*table, error) {
+	sql, err := renderProfileTemplate(text, data)
+	if err != nil {
+		return nil, err
+	}
+	result, err := p.sess.CallTool(ctx, p.cfg.QueryTool, map[string]interface{}{p.cfg.QueryArgument: sql})
+	if err != nil {
+		return nil, err
+	}
+	if result.IsError {
+		return nil, fmt.Errorf("query failed: %s", abbreviate(resultText(result), 200))
+	}
+	t, ok := resultTable(result)
+	if !ok {
+		return nil, fmt.Errorf("query returned no row data: %s", abbreviate(resultText(result), 200))
+	}
+	return t, nil
+}
+
+// cellString returns the value of a named column, matched
+// case-insensitively, as trimmed text.
+func cellString(t *table, row []interface{}, name string) string {
+	for i, c := range t.Columns {
+		if strings.EqualFold(c.Name, name) {
+			return strings.TrimSpace(formatCell(row[i]))
+		}
+	}
+	return ""
+}
+
+// expand lists the tables matching a database.pattern argument.
+func (p *profiler) expand(ctx context.Context, pattern string) ([]string, error) {
+	t, err := p.query(ctx, p.cfg.TablesQuery, templateData("", "", pattern))
+	if err != nil {
+		return nil, err
+	}
+	var tables []string
+	for _, row := range t.Rows {
+		db, name := cellString(t, row, "DatabaseName"), cellString(t, row, "TableName")
+		if name == "" {
+			continue
+		}
+		if db != "" {
+			name = db + "." + name
+		}
+		tables = append(tables, name)
+	}
+	return tables, nil
+}
+
+// columns lists the columns of a table through the dictionary query.
+func (p *profiler) columns(ctx context.Context, table string) ([]profileColumn, error) {
+	t, err := p.query(ctx, p.cfg.ColumnsQuery, templateData(table, "", ""))
+	if err != nil {
+		return nil, err
+	}
+	var cols []profileColumn
+	for _, row := range t.Rows {
+		name := cellString(t, row, "ColumnName")
+		if name == "" {
+			continue
+		}
+		kind := cellString(t, row, "ColumnType")
+		numeric := false
+		for _, n := range p.cfg.NumericTypes {
+			if strings.EqualFold(kind, n) {
+				numeric = true
+				break
+			}
+		}
+		cols = append(cols, profileColumn{Name: name, Numeric: numeric})
+	}
+	return cols, nil
+}
+
+// profile runs every table and column tool for one table. Failures are
+// recorded in the report rather than stopping the run.
+func (p *profiler) profile(ctx context.Context, table string) tableProfile {
+	tp := tableProfile{Table: table}
+	for _, tool := range p.cfg.TableTools {
+		tp.Sections = append(tp.Sections, p.call(ctx, tool, templateData(table, "", "")))
+	}
+	if len(p.cfg.ColumnTools) == 0 {
+		return tp
+	}
+
+	cols, err := p.columns(ctx, table)
+	if err != nil {
+		tp.Error = fmt.Sprintf("list columns: %v", err)
+		return tp
+	}
+	for _, col := range cols {
+		for _, tool := range p.cfg.ColumnTools {
+			if tool.NumericOnly && !col.Numeric {
+				continue
+			}
+			section := p.call(ctx, tool, templateData(table, col.Name, ""))
+			section.Column = col.Name
+			tp.Sections = append(tp.Sections, section)
+		}
+	}
+	return tp
+}
+
+// call runs one profile tool and captures its output.
+func (p *profiler) call(ctx context.Context, tool profileTool, data map[string]interface{}) profileSection {
+	section := profileSection{Title: tool.Title, Tool: tool.Tool}
+	args := map[string]interface{}{}
+	for k, v := range tool.Arguments {
+		rendered, err := renderProfileTemplate(v, data)
+		if err != nil {
+			section.Error = fmt.Sprintf("argument %s: %v", k, err)
+			return section
+		}
+		args[k] = rendered
+	}
+
+	result, err := p.sess.CallTool(ctx, tool.Tool, args)
+	if err != nil {
+		section.Error = p.sess.redact.Text(err.Error())
+		return section
+	}
+	result = p.sess.redact.Result(result)
+	if result.IsError {
+		section.Error = resultText(result)
+		return section
+	}
+	if t, ok := resultTable(result); ok {
+		section.table = t
+		for _, c := range t.Columns {
+			section.Columns = append(section.Columns, c.Name)
+		}
+		for _, row := range tableMaps(t) {
+			section.Rows = append(section.Rows, row.(map[string]interface{}))
+		}
+		return section
+	}
+	section.Text = resultText(result)
+	return section
+}
+
+// writeProfileMarkdown renders the report as Markdown.
+func writeProfileMarkdown(w io.Writer, r *profileReport) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Data profile\n\nServer: %s  \nGenerated: %s\n", r.Server, r.Generated.Format(time.RFC3339))
+	for _, t := range r.Tables {
+		fmt.Fprintf(&b, "\n## %s\n", t.Table)
+		if t.Error != "" {
+			fmt.Fprintf(&b, "\n**Error:** %s\n", t.Error)
+		}
+		for _, s := range t.Sections {
+			title := s.Title
+			if s.Column != "" {
+				title += ": " + s.Column
+			}
+			fmt.Fprintf(&b, "\n### %s\n\n", title)
+			switch {
+			case s.Error != "":
+				fmt.Fprintf(&b, "**Error:** %s\n", strings.TrimSpace(s.Error))
+			case s.table != nil:
+				writeMarkdownTable(&b, s.table)
+			default:
+				fmt.Fprintf(&b, "```\n%s\n```\n", strings.TrimSpace(s.Text))
+			}
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeMarkdownTable renders a table as a GitHub-flavored Markdown table.
+func writeMarkdownTable(b *strings.Builder, t *table) {
+	escape := func(s string) string {
+		return strings.ReplaceAll(strings.Join(strings.Fields(s), " "), "|", `\|`)
+	}
+	for _, c := range t.Columns {
+		fmt.Fprintf(b, "| %s ", escape(c.Name))
+	}
+	b.WriteString("|\n")
+	for _, c := range t.Columns {
+		if c.Kind == kindInt || c.Kind == kindFloat {
+			b.WriteString("| ---: ")
+		} else {
+			b.WriteString("| --- ")
+		}
+	}
+	b.WriteString("|\n")
+	for _, row := range t.Rows {
+		for _, v := range row {
+			cell := "NULL"
+			if v != nil {
+				cell = escape(formatCell(v))
+			}
+			fmt.Fprintf(b, "| %s ", cell)
+		}
+		b.WriteString("|\n")
+	}
+}
+
+var profileHTML = template.Must(template.New("profile").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Data profile</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin: 0.5em 0; }
+th, td { border: 1px solid #ccc; padding: 2px 8px; }
+.error { color: #b00; }
+</style></head><body>
+<h1>Data profile</h1>
+<p>Server: {{ .Server }}<br>Generated: {{ .Generated.Format "2006-01-02T15:04:05Z07:00" }}</p>
+{{ range .Tables }}<h2>{{ .Table }}</h2>
+{{ if .Error }}<p class="error">{{ .Error }}</p>{{ end }}
+{{ range .Sections }}<h3>{{ .Title }}{{ if .Column }}: {{ .Column }}{{ end }}</h3>
+{{ if .Error }}<p class="error">{{ .Error }}</p>
+{{ else if .Columns }}{{ $cols := .Columns }}<table><tr>{{ range $cols }}<th>{{ . }}</th>{{ end }}</tr>
+{{ range .Rows }}{{ $row := . }}<tr>{{ range $cols }}<td>{{ index $row . }}</td>{{ end }}</tr>
+{{ end }}</table>
+{{ else }}<pre>{{ .Text }}</pre>
+{{ end }}{{ end }}{{ end }}</body></html>
+`))
+
+// writeProfileHTML renders the report as a standalone HTML page.
+func writeProfileHTML(w io.Writer, r *profileReport) error {
+	return profileHTML.Execute(w, r)
+}
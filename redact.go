@@ -0,0 +1,344 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// redactedValue replaces every redacted value.
+const redactedValue = "[REDACTED]"
+
+// redactConfig lists what is hidden from logs, printed output and audit
+// records.
+//
+//	"redact": {
+//	  "fields": ["password", "token"],
+//	  "paths": ["$.credentials.secret", "$.rows[*].ssn"],
+//	  "patterns": ["AKIA[0-9A-Z]{16}"],
+//	  "builtin": ["email", "creditCard"]
+//	}
+type redactConfig struct {
+	// Fields are object keys, matched case-insensitively at any depth.
+	Fields []string `json:"fields,omitempty"`
+	// Paths are JSON paths from the root of the arguments or result.
+	// Segments are keys, [n] indexes and * or [*] wildcards.
+	Paths []string `json:"paths,omitempty"`
+	// Patterns are regular expressions replaced inside any string.
+	Patterns []string `json:"patterns,omitempty"`
+	// Builtin enables named patterns: email, creditCard and bearer.
+	Builtin []string `json:"builtin,omitempty"`
+}
+
+// builtinPatterns are the patterns available through redactConfig.Builtin.
+var builtinPatterns = map[string]string{
+	"email":      `[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`,
+	"creditCard": `\b(?:\d[ -]?){12,18}\d\b`,
+	"bearer":     `(?i)\bbearer\s+[A-Za-z0-9._~+/-]+=*`,
+}
+
+// redactor hides configured values. A nil or disabled redactor returns
+// everything unchanged.
+type redactor struct {
+	fields   []string
+	paths    [][]string
+	patterns []*regexp.Regexp
+	luhn     map[*regexp.Regexp]bool // patterns whose matches must pass a Luhn check
+	disabled bool
+}
+
+func newRedactor(cfg redactConfig) (*redactor, error) {
+	r := &redactor{fields: cfg.Fields, luhn: make(map[*regexp.Regexp]bool)}
+	for _, p := range cfg.Paths {
+		segs, err := parseJSONPath(p)
+		if err != nil {
+			return nil, fmt.Errorf("redact: %w", err)
+		}
+		r.paths = append(r.paths, segs)
+	}
+	for _, p := range cfg.Patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("redact: bad pattern %q: %w", p, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	for _, name := range cfg.Builtin {
+		p, ok := builtinPatterns[name]
+		if !ok {
+			return nil, fmt.Errorf("redact: unknown builtin %q", name)
+		}
+		re := regexp.MustCompile(p)
+		r.patterns = append(r.patterns, re)
+		r.luhn[re] = name == "creditCard"
+	}
+	return r, nil
+}
+
+// withFields returns an enabled copy of r that also hides fields. The
+// audit log uses it so its records stay redacted even with --no-redact.
+func (r *redactor) withFields(fields []string) *redactor {
+	out := &redactor{}
+	if r != nil {
+		*out = *r
+	}
+	out.fields = append(append([]string{}, out.fields...), fields...)
+	out.disabled = false
+	return out
+}
+
+func (r *redactor) active() bool {
+	return r != nil && !r.disabled && (len(r.fields) > 0 || len(r.paths) > 0 || len(r.patterns) > 0)
+}
+
+// parseJSONPath splits "$.a.b[*].c" or "$.a[0]" into segments.
+func parseJSONPath(p string) ([]string, error) {
+	rest := strings.TrimPrefix(p, "$")
+	var segs []string
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("bad JSON path %q", p)
+			}
+			segs = append(segs, rest[:end])
+			rest = rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("bad JSON path %q", p)
+			}
+			segs = append(segs, strings.Trim(rest[1:end], `'"`))
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("bad JSON path %q", p)
+		}
+	}
+	if len(segs) == 0 {
+		return nil, fmt.Errorf("bad JSON path %q", p)
+	}
+	return segs, nil
+}
+
+// Args returns a redacted copy of tool arguments.
+func (r *redactor) Args(args map[string]interface{}) map[string]interface{} {
+	if !r.active() || args == nil {
+		return args
+	}
+	v, _ := r.Value(args)
+	return v.(map[string]interface{})
+}
+
+// Value returns a redacted copy of a decoded JSON value and whether
+// anything was hidden.
+func (r *redactor) Value(v interface{}) (interface{}, bool) {
+	if !r.active() {
+		return v, false
+	}
+	out, changed := r.walk(v)
+	for _, segs := range r.paths {
+		if redactPath(out, segs) {
+			changed = true
+		}
+	}
+	return out, changed
+}
+
+// walk copies v, hiding configured fields and pattern matches.
+func (r *redactor) walk(v interface{}) (interface{}, bool) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		changed := false
+		for k, item := range v {
+			if r.isField(k) {
+				out[k] = redactedValue
+				changed = true
+				continue
+			}
+			var c bool
+			out[k], c = r.walk(item)
+			changed = changed || c
+		}
+		return out, changed
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		changed := false
+		for i, item := range v {
+			var c bool
+			out[i], c = r.walk(item)
+			changed = changed || c
+		}
+		return out, changed
+	case string:
+		s := r.Text(v)
+		return s, s != v
+	}
+	return v, false
+}
+
+func (r *redactor) isField(key string) bool {
+	for _, f := range r.fields {
+		if strings.EqualFold(key, f) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactPath hides the values at segs inside v, which must already be a
+// private copy.
+func redactPath(v interface{}, segs []string) bool {
+	seg, last := segs[0], len(segs) == 1
+	changed := false
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, item := range v {
+			if seg != "*" && seg != k {
+				continue
+			}
+			if last {
+				v[k] = redactedValue
+				changed = true
+			} else if redactPath(item, segs[1:]) {
+				changed = true
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			if seg != "*" && seg != strconv.Itoa(i) {
+				continue
+			}
+			if last {
+				v[i] = redactedValue
+				changed = true
+			} else if redactPath(item, segs[1:]) {
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// Text replaces pattern matches inside free text.
+func (r *redactor) Text(s string) string {
+	if !r.active() {
+		return s
+	}
+	for _, re := range r.patterns {
+		if !r.luhn[re] {
+			s = re.ReplaceAllString(s, redactedValue)
+			continue
+		}
+		s = re.ReplaceAllStringFunc(s, func(m string) string {
+			if luhnValid(m) {
+				return redactedValue
+			}
+			return m
+		})
+	}
+	return s
+}
+
+// JSONText redacts text that may hold a JSON document. JSON is decoded so
+// field and path rules apply; anything else only gets the patterns.
+func (r *redactor) JSONText(s string) string {
+	if !r.active() {
+		return s
+	}
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		return r.Text(s)
+	}
+	if _, ok := v.(string); ok {
+		return r.Text(s)
+	}
+	out, changed := r.Value(v)
+	if !changed {
+		return s
+	}
+	data, err := json.Marshal(out)
+	if err != nil {
+		return r.Text(s)
+	}
+	return string(data)
+}
+
+// Result returns a redacted copy of a tool result.
+func (r *redactor) Result(res *toolResult) *toolResult {
+	if !r.active() || res == nil {
+		return res
+	}
+	out := *res
+	out.Content = make([]contentItem, len(res.Content))
+	for i, c := range res.Content {
+		c.Text = r.JSONText(c.Text)
+		if c.Resource != nil {
+			rc := *c.Resource
+			rc.Text = r.JSONText(rc.Text)
+			c.Resource = &rc
+		}
+		out.Content[i] = c
+	}
+	if len(res.StructuredContent) > 0 {
+		out.StructuredContent = json.RawMessage(r.JSONText(string(res.StructuredContent)))
+	}
+	return &out
+}
+
+// Resources returns redacted copies of resource contents.
+func (r *redactor) Resources(contents []resourceContent) []resourceContent {
+	if !r.active() {
+		return contents
+	}
+	out := make([]resourceContent, len(contents))
+	for i, c := range contents {
+		c.Text = r.JSONText(c.Text)
+		out[i] = c
+	}
+	return out
+}
+
+// luhnValid reports whether the digits in s pass the Luhn checksum, which
+// keeps long numeric IDs from being mistaken for card numbers.
+func luhnValid(s string) bool {
+	sum, double := 0, false
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// redactingWriter applies the text patterns to everything written through
+// it. It is installed as the output of the standard logger.
+type redactingWriter struct {
+	w io.Writer
+	r *redactor
+}
+
+func (rw redactingWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(rw.w, rw.r.Text(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
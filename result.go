@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// toolResult is a transport-neutral view of a tool call result. It is
+// decoded from the JSON form of the result so that every content type,
+// including ones the MCP library has no Go type for, is preserved.
+type toolResult struct {
+	Content           []contentItem   `json:"content"`
+	StructuredContent json.RawMessage `json:"structuredContent,omitempty"`
+	IsError           bool            `json:"isError,omitempty"`
+}
+
+// contentItem is one entry of a tool result's content list.
+type contentItem struct {
+	Type     string           `json:"type"`
+	Text     string           `json:"text,omitempty"`
+	Data     string           `json:"data,omitempty"`
+	MimeType string           `json:"mimeType,omitempty"`
+	Resource *resourceContent `json:"resource,omitempty"`
+}
+
+// resourceContent is the payload of an embedded resource content item.
+type resourceContent struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text,omitempty"`
+	Blob     string `json:"blob,omitempty"`
+}
+
+// decodeToolResult converts a library result into a toolResult.
+func decodeToolResult(result interface{}) (*toolResult, error) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("encode result: %w", err)
+	}
+	var r toolResult
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("decode result: %w", err)
+	}
+	return &r, nil
+}
+
+// promptResult is a transport-neutral view of a GetPrompt result.
+type promptResult struct {
+	Description string          `json:"description,omitempty"`
+	Messages    []promptMessage `json:"messages"`
+}
+
+// promptMessage is one message of a rendered prompt.
+type promptMessage struct {
+	Role    string      `json:"role"`
+	Content contentItem `json:"content"`
+}
+
+// decodePromptResult converts a library GetPrompt result.
+func decodePromptResult(result interface{}) (*promptResult, error) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("encode result: %w", err)
+	}
+	var r promptResult
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("decode result: %w", err)
+	}
+	return &r, nil
+}
+
+// resultValue returns the data a tool result carries: the structured
+// content when present, else the text content decoded as JSON when it
+// is JSON, else the text itself.
+func resultValue(r *toolResult) interface{} {
+	if len(r.StructuredContent) > 0 && string(r.StructuredContent) != "null" {
+		var v interface{}
+		if err := json.Unmarshal(r.StructuredContent, &v); err == nil {
+			return v
+		}
+	}
+	text := resultText(r)
+	var v interface{}
+	if err := json.Unmarshal([]byte(text), &v); err == nil {
+		return v
+	}
+	return text
+}
+
+// resultText joins the text items of a result with newlines.
+func resultText(r *toolResult) string {
+	var parts []string
+	for _, c := range r.Content {
+		if c.Type == "text" {
+			parts = append(parts, c.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// decodeResourceContents converts a library ReadResource result into the
+// list of contents it carries.
+func decodeResourceContents(result interface{}) ([]resourceContent, error) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("encode result: %w", err)
+	}
+	var r struct {
+		Contents []resourceContent `json:"contents"`
+	}
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("decode result: %w", err)
+	}
+	return r.Contents, nil
+}
+
+// printResourceContents writes the text of each content, noting binary
+// contents by type.
+func printResourceContents(w io.Writer, uri string, contents []resourceContent) {
+	fmt.Fprintf(w, "\nContents of %s:\n", uri)
+	for _, c := range contents {
+		if c.Blob != "" {
+			fmt.Fprintf(w, "Binary content of type %s received\n", c.MimeType)
+			continue
+		}
+		fmt.Fprintln(w, c.Text)
+	}
+}
+
+// printToolResult writes a result the same way python-client.py does,
+// except that row data is rendered as an aligned table.
+func printToolResult(w io.Writer, name string, r *toolResult) {
+	fmt.Fprintf(w, "\nResults from %s:\n", name)
+	if r.IsError {
+		fmt.Fprintln(w, "Tool reported an error:")
+	}
+
+	structuredTable := false
+	if len(r.StructuredContent) > 0 && string(r.StructuredContent) != "null" {
+		fmt.Fprintln(w, "Structured content:")
+		if t, ok := parseTable(r.StructuredContent); ok {
+			renderTable(w, t)
+			structuredTable = true
+		} else {
+			var v interface{}
+			if err := json.Unmarshal(r.StructuredContent, &v); err == nil {
+				out, _ := json.MarshalIndent(v, "", "  ")
+				fmt.Fprintln(w, string(out))
+			} else {
+				fmt.Fprintln(w, string(r.StructuredContent))
+			}
+		}
+	}
+
+	for _, c := range r.Content {
+		if c.Type != "text" {
+			fmt.Fprintf(w, "Content of type %s received\n", c.Type)
+			continue
+		}
+		// Servers often repeat structured content as JSON text
+		if t, ok := parseTable([]byte(c.Text)); ok {
+			if !structuredTable {
+				renderTable(w, t)
+			}
+			continue
+		}
+		fmt.Fprintln(w, c.Text)
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ThinkInAIXYZ/go-mcp/protocol"
+)
+
+// resultCacheConfig controls caching of tool results. Tools annotated as
+// both read-only and idempotent are cached, as are the tools listed here.
+//
+//	"cache": {"ttl": "5m", "store": "disk", "tools": ["list_*"], "ttls": {"list_distinct_values": "1h"}}
+type resultCacheConfig struct {
+	// TTL is how long a result is reused, as a Go duration. "0" disables
+	// the cache. The default is five minutes.
+	TTL string `json:"ttl,omitempty"`
+	// Store is "memory" (the default), which lasts for one invocation, or
+	// "disk", which shares results between invocations.
+	Store string `json:"store,omitempty"`
+	// Dir overrides the disk cache directory, which defaults to
+	// mcp-client/results under the user's cache directory.
+	Dir string `json:"dir,omitempty"`
+	// Tools are upstream tool name patterns to cache whatever their
+	// annotations say.
+	Tools []string `json:"tools,omitempty"`
+	// TTLs overrides the TTL for matching upstream tool names. A matching
+	// tool is cached even without annotations; "0" turns caching off.
+	TTLs map[string]string `json:"ttls,omitempty"`
+}
+
+// cachedResult is one stored tool result.
+type cachedResult struct {
+	Stored  time.Time       `json:"stored"`
+	Expires time.Time       `json:"expires"`
+	Result  json.RawMessage `json:"result"`
+}
+
+// resultCache stores tool results keyed by server, tool and arguments.
+// A nil *resultCache caches nothing.
+type resultCache struct {
+	ttl   time.Duration
+	tools []string
+	ttls  map[string]time.Duration
+	dir   string // disk store; empty for memory
+
+	mu  sync.Mutex
+	mem map[string]cachedResult
+}
+
+func newResultCache(cfg resultCacheConfig) (*resultCache, error) {
+	c := &resultCache{ttl: 5 * time.Minute, tools: cfg.Tools, ttls: map[string]time.Duration{}, mem: map[string]cachedResult{}}
+	if cfg.TTL != "" {
+		ttl, err := time.ParseDuration(cfg.TTL)
+		if err != nil {
+			return nil, fmt.Errorf("cache ttl: %w", err)
+		}
+		c.ttl = ttl
+	}
+	for pattern, value := range cfg.TTLs {
+		ttl, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("cache ttl for %s: %w", pattern, err)
+		}
+		c.ttls[pattern] = ttl
+	}
+
+	switch cfg.Store {
+	case "", "memory":
+	case "disk":
+		c.dir = cfg.Dir
+		if c.dir == "" {
+			base, err := os.UserCacheDir()
+			if err != nil {
+				return nil, fmt.Errorf("cache: %w", err)
+			}
+			c.dir = filepath.Join(base, "mcp-client", "results")
+		}
+	default:
+		return nil, fmt.Errorf("cache store %q: expected memory or disk", cfg.Store)
+	}
+	return c, nil
+}
+
+// ttlFor returns how long results of tool may be reused, or zero when
+// they must not be cached.
+func (c *resultCache) ttlFor(tool *protocol.Tool) time.Duration {
+	if c == nil {
+		return 0
+	}
+	// The most specific pattern wins, so sort longest first
+	patterns := make([]string, 0, len(c.ttls))
+	for pattern := range c.ttls {
+		patterns = append(patterns, pattern)
+	}
+	sort.Slice(patterns, func(i, j int) bool { return len(patterns[i]) > len(patterns[j]) })
+	for _, pattern := range patterns {
+		if matchAny([]string{pattern}, tool.Name) {
+			return c.ttls[pattern]
+		}
+	}
+
+	if matchAny(c.tools, tool.Name) ||
+		(annotationHint(tool, "readOnlyHint") && annotationHint(tool, "idempotentHint")) {
+		return c.ttl
+	}
+	return 0
+}
+
+// resultCacheKey identifies a call. Maps are encoded with sorted keys, so
+// the same arguments always give the same key.
+func resultCacheKey(server, tool string, args map[string]interface{}) (string, error) {
+	data, err := json.Marshal(args)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00", server, tool)
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// get returns the unexpired result stored under key and when it was stored.
+func (c *resultCache) get(key string) (*toolResult, time.Time, bool) {
+	var entry cachedResult
+	if c.dir == "" {
+		c.mu.Lock()
+		e, ok := c.mem[key]
+		c.mu.Unlock()
+		if !ok {
+			return nil, time.Time{}, false
+		}
+		entry = e
+	} else {
+		data, err := os.ReadFile(c.path(key))
+		if err != nil || json.Unmarshal(data, &entry) != nil {
+			return nil, time.Time{}, false
+		}
+	}
+
+	if time.Now().After(entry.Expires) {
+		c.drop(key)
+		return nil, time.Time{}, false
+	}
+	var r toolResult
+	if err := json.Unmarshal(entry.Result, &r); err != nil {
+		return nil, time.Time{}, false
+	}
+	return &r, entry.Stored, true
+}
+
+// put stores result under key for ttl.
+func (c *resultCache) put(key string, result *toolResult, ttl time.Duration) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	entry := cachedResult{Stored: now, Expires: now.Add(ttl), Result: data}
+	if c.dir == "" {
+		c.mu.Lock()
+		c.mem[key] = entry
+		c.mu.Unlock()
+		return nil
+	}
+	if data, err = json.Marshal(entry); err != nil {
+		return err
+	}
+	return writeCacheFile(c.path(key), data)
+}
+
+func (c *resultCache) drop(key string) {
+	if c.dir == "" {
+		c.mu.Lock()
+		delete(c.mem, key)
+		c.mu.Unlock()
+		return
+	}
+	os.Remove(c.path(key))
+}
+
+func (c *resultCache) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key+".json")
+}
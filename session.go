@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ThinkInAIXYZ/go-mcp/protocol"
+)
+
+// mcpConn is the part of the MCP client a session uses. It is satisfied by
+// rpcMux, which talks to the server, and by daemonConn, which routes
+// through the daemon.
+type mcpConn interface {
+	ListTools(ctx context.Context) (*protocol.ListToolsResult, error)
+	CallTool(ctx context.Context, request *protocol.CallToolRequest) (*protocol.CallToolResult, error)
+	ReadResource(ctx context.Context, request *protocol.ReadResourceRequest) (*protocol.ReadResourceResult, error)
+	GetPrompt(ctx context.Context, request *protocol.GetPromptRequest) (*protocol.GetPromptResult, error)
+	ListResources(ctx context.Context) (*protocol.ListResourcesResult, error)
+	ListPrompts(ctx context.Context) (*protocol.ListPromptsResult, error)
+	GetServerInfo() protocol.Implementation
+	Close() error
+}
+
+// session wraps an MCP client and applies the local tool policy to every
+// listing and call that goes through it. It is safe for concurrent use;
+// calls from many goroutines share the one connection.
+type session struct {
+	*app
+	server string
+	conn   mcpConn
+
+	mu       sync.Mutex
+	catalog  []*protocol.Tool // upstream tools, fetched on first use
+	cached   bool             // catalog came from the on-disk cache
+	watchers []chan rpcNotification
+}
+
+// rpcNotification is a notification passed on to watchers.
+type rpcNotification struct {
+	Method string
+	Params json.RawMessage
+}
+
+// Close closes the connection to the server.
+func (s *session) Close() error {
+	return s.conn.Close()
+}
+
+// handleNotification drops cached lists the server says have changed and
+// passes the notification on to watchers.
+func (s *session) handleNotification(ctx context.Context, method string, params json.RawMessage) {
+	s.catalogs.listChangedHandler(s.server, s.dropTools)(ctx, method, params)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, ch := range s.watchers {
+		select {
+		case ch <- rpcNotification{Method: method, Params: params}:
+		default: // the watcher is behind; it re-reads everything anyway
+		}
+	}
+}
+
+// notifications returns a channel receiving the server's notifications.
+// Only direct connections deliver them; through the daemon it stays quiet.
+func (s *session) notifications() <-chan rpcNotification {
+	ch := make(chan rpcNotification, 16)
+	s.mu.Lock()
+	s.watchers = append(s.watchers, ch)
+	s.mu.Unlock()
+	return ch
+}
+
+// capabilities returns what the server advertised at initialize, and false
+// when that is unknown because the session goes through the daemon.
+func (s *session) capabilities() (protocol.ServerCapabilities, bool) {
+	if m, ok := s.conn.(*rpcMux); ok {
+		return m.GetServerCapabilities(), true
+	}
+	return protocol.ServerCapabilities{}, false
+}
+
+// SubscribeResource asks the server to send notifications/resources/updated
+// when the resource at uri changes.
+func (s *session) SubscribeResource(ctx context.Context, uri string) error {
+	m, ok := s.conn.(*rpcMux)
+	if !ok {
+		return errors.New("resource subscriptions need a direct connection")
+	}
+	return m.call(ctx, "resources/subscribe", protocol.NewSubscribeRequest(uri), nil)
+}
+
+// serverVersion identifies the server build, so cached catalogs from a
+// different version are not used. It is empty when the server does not say.
+func (s *session) serverVersion() string {
+	info := s.conn.GetServerInfo()
+	if info.Name == "" && info.Version == "" {
+		return ""
+	}
+	return info.Name + "/" + info.Version
+}
+
+// upstreamTools returns the server's unfiltered tool catalog, from the
+// on-disk cache when it is fresh.
+func (s *session) upstreamTools(ctx context.Context) ([]*protocol.Tool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.catalog != nil {
+		return s.catalog, nil
+	}
+	version := s.serverVersion()
+	var tools []*protocol.Tool
+	if s.catalogs.load(s.server, catalogTools, version, &tools) && tools != nil {
+		s.catalog, s.cached = tools, true
+		return s.catalog, nil
+	}
+
+	result, err := s.conn.ListTools(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.catalog, s.cached = result.Tools, false
+	if s.catalog == nil {
+		s.catalog = []*protocol.Tool{}
+	}
+	s.storeCatalog(catalogTools, version, s.catalog)
+	return s.catalog, nil
+}
+
+// dropTools forgets the tool catalog, in memory and on disk, so the next
+// use fetches it from the server.
+func (s *session) dropTools() {
+	s.mu.Lock()
+	s.catalog, s.cached = nil, false
+	s.mu.Unlock()
+	s.catalogs.invalidate(s.server, catalogTools)
+}
+
+func (s *session) fromCache() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cached
+}
+
+func (s *session) storeCatalog(kind, version string, items interface{}) {
+	if err := s.catalogs.store(s.server, kind, version, items); err != nil {
+		log.Printf("Failed to cache %s list: %v", kind, err)
+	}
+}
+
+// ListTools returns the tools visible under the policy, renamed and with
+// descriptions overridden.
+func (s *session) ListTools(ctx context.Context) ([]*protocol.Tool, error) {
+	catalog, err := s.upstreamTools(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.policy.checkNames(catalog); err != nil {
+		return nil, err
+	}
+	return s.policy.apply(catalog), nil
+}
+
+// CallTool calls the tool the user knows as name. Tools hidden by the
+// policy or carrying SQL the guard refuses are rejected without contacting
+// the server, and destructive tools are only called once the user confirms.
+// Every attempt is written to the audit log, including refused ones.
+func (s *session) CallTool(ctx context.Context, name string, args map[string]interface{}) (*toolResult, error) {
+	var info callInfo
+	result, err := s.callTool(ctx, name, args, &info)
+
+	rec := &auditRecord{Server: s.server, Kind: "tool", Tool: name, Arguments: args, Cached: info.cached}
+	if auditErr := s.audit.logCall(rec, info.start, result, err); auditErr != nil {
+		log.Printf("Failed to write audit record: %v", auditErr)
+	}
+	return result, err
+}
+
+// callInfo describes how a call was served, for its audit record.
+type callInfo struct {
+	start  time.Time // when the request was sent; zero if it never was
+	cached bool      // answered from the result cache
+}
+
+// callTool runs the checks and the call itself, or answers it from the
+// result cache, filling in info.
+func (s *session) callTool(ctx context.Context, name string, args map[string]interface{}, info *callInfo) (*toolResult, error) {
+	catalog, err := s.upstreamTools(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tool, err := s.policy.resolve(name, catalog)
+	if err != nil && s.fromCache() {
+		// The cached catalog may predate the tool, so ask the server
+		s.dropTools()
+		if catalog, err = s.upstreamTools(ctx); err != nil {
+			return nil, err
+		}
+		tool, err = s.policy.resolve(name, catalog)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := s.guard.check(tool.Name, args); err != nil {
+		return nil, err
+	}
+	if err := s.confirm.check(name, tool, args); err != nil {
+		return nil, err
+	}
+
+	// Read-only results may come from the cache
+	var key string
+	ttl := s.results.ttlFor(tool)
+	if ttl > 0 {
+		if key, err = resultCacheKey(s.server, tool.Name, args); err != nil {
+			return nil, err
+		}
+		if cached, stored, ok := s.results.get(key); ok {
+			if s.verbose {
+				log.Printf("Cache hit for %s (stored %s ago)", name, time.Since(stored).Round(time.Second))
+			}
+			info.start, info.cached = time.Now(), true
+			return cached, nil
+		}
+		if s.verbose {
+			log.Printf("Cache miss for %s", name)
+		}
+	}
+
+	info.start = time.Now()
+	raw, err := s.conn.CallTool(ctx, protocol.NewCallToolRequest(tool.Name, args))
+	if err != nil {
+		return nil, fmt.Errorf("call %s: %w", name, err)
+	}
+	result, err := decodeToolResult(raw)
+	if err == nil && ttl > 0 && !result.IsError {
+		if err := s.results.put(key, result, ttl); err != nil {
+			log.Printf("Failed to cache result of %s: %v", name, err)
+		}
+	}
+	return result, err
+}
+
+// ReadResource reads the resource at uri and records the read in the
+// audit log.
+func (s *session) ReadResource(ctx context.Context, uri string) ([]resourceContent, error) {
+	start := time.Now()
+	contents, err := s.readResource(ctx, uri)
+
+	rec := &auditRecord{Server: s.server, Kind: "resource", Resource: uri}
+	if auditErr := s.audit.logCall(rec, start, contents, err); auditErr != nil {
+		log.Printf("Failed to write audit record: %v", auditErr)
+	}
+	return contents, err
+}
+
+func (s *session) readResource(ctx context.Context, uri string) ([]resourceContent, error) {
+	result, err := s.conn.ReadResource(ctx, protocol.NewReadResourceRequest(uri))
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", uri, err)
+	}
+	return decodeResourceContents(result)
+}
+
+// GetPrompt renders the named prompt with the given arguments.
+func (s *session) GetPrompt(ctx context.Context, name string, args map[string]string) (*promptResult, error) {
+	result, err := s.conn.GetPrompt(ctx, protocol.NewGetPromptRequest(name, args))
+	if err != nil {
+		return nil, fmt.Errorf("get prompt %s: %w", name, err)
+	}
+	return decodePromptResult(result)
+}
+
+// ListResources returns the server's resources, from the on-disk cache
+// when it is fresh.
+func (s *session) ListResources(ctx context.Context) ([]*protocol.Resource, error) {
+	version := s.serverVersion()
+	var resources []*protocol.Resource
+	if s.catalogs.load(s.server, catalogResources, version, &resources) {
+		return resources, nil
+	}
+	result, err := s.conn.ListResources(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list resources: %w", err)
+	}
+	s.storeCatalog(catalogResources, version, result.Resources)
+	return result.Resources, nil
+}
+
+// ListPrompts returns the server's prompts, from the on-disk cache when it
+// is fresh.
+func (s *session) ListPrompts(ctx context.Context) ([]protocol.Prompt, error) {
+	version := s.serverVersion()
+	var prompts []protocol.Prompt
+	if s.catalogs.load(s.server, catalogPrompts, version, &prompts) {
+		return prompts, nil
+	}
+	result, err := s.conn.ListPrompts(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list prompts: %w", err)
+	}
+	s.storeCatalog(catalogPrompts, version, result.Prompts)
+	return result.Prompts, nil
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// sqlGuardRule marks a tool argument as holding SQL that must be read-only.
+//
+//	"sqlGuard": [{"tool": "query", "argument": "query"}]
+type sqlGuardRule struct {
+	// Tool is the upstream tool name or glob.
+	Tool string `json:"tool"`
+	// Argument is the name of the argument carrying the SQL text.
+	Argument string `json:"argument"`
+}
+
+// sqlGuard rejects tool calls whose SQL arguments would modify data.
+type sqlGuard struct {
+	rules []sqlGuardRule
+}
+
+// readOnlyStatements are the leading keywords a guarded statement may
+// start with. SEL is the Teradata abbreviation of SELECT.
+var readOnlyStatements = map[string]bool{
+	"SELECT":  true,
+	"SEL":     true,
+	"WITH":    true,
+	"HELP":    true,
+	"SHOW":    true,
+	"EXPLAIN": true,
+	"LOCKING": true,
+	"LOCK":    true,
+}
+
+// statementKinds explains why known keywords are refused.
+var statementKinds = map[string]string{
+	"INSERT": "DML", "INS": "DML", "UPDATE": "DML", "UPD": "DML",
+	"DELETE": "DML", "DEL": "DML", "MERGE": "DML", "UPSERT": "DML",
+	"TRUNCATE": "DML",
+	"CREATE":   "DDL", "DROP": "DDL", "ALTER": "DDL", "RENAME": "DDL",
+	"REPLACE": "DDL", "MODIFY": "DDL", "COMMENT": "DDL", "COLLECT": "DDL",
+	"GRANT": "DCL", "REVOKE": "DCL", "GIVE": "DCL",
+	"CALL": "procedure call", "EXEC": "macro execution", "EXECUTE": "macro execution",
+	"BT": "transaction control", "ET": "transaction control",
+	"BEGIN": "transaction control", "END": "transaction control",
+	"COMMIT": "transaction control", "ROLLBACK": "transaction control", "ABORT": "transaction control",
+	"DATABASE": "session control", "SET": "session control",
+}
+
+// modifyingKeywords may not appear anywhere in a guarded statement: WITH
+// and LOCKING can prefix a data-modifying statement, and SELECT AND
+// CONSUME deletes the rows it reads from a queue table. REPLACE is left
+// out since it is also a string function.
+var modifyingKeywords = map[string]bool{
+	"INSERT": true, "INS": true, "UPDATE": true, "UPD": true,
+	"DELETE": true, "DEL": true, "MERGE": true, "UPSERT": true,
+	"CONSUME": true, "CREATE": true, "DROP": true, "ALTER": true,
+	"RENAME": true, "MODIFY": true, "GRANT": true, "REVOKE": true,
+	"CALL": true, "EXEC": true, "EXECUTE": true,
+}
+
+// lockingTarget skips the LOCKING modifiers at the start of words, such
+// as "LOCKING TABLE t FOR ACCESS" or "LOCK ROW IN READ MODE NOWAIT", and
+// returns the keyword of the statement they apply to.
+func lockingTarget(words []string) string {
+	i := 0
+	for i < len(words) && (words[i] == "LOCKING" || words[i] == "LOCK") {
+		for i < len(words) && words[i] != "FOR" && words[i] != "IN" {
+			i++
+		}
+		i += 2 // FOR or IN, and the lock type
+		for i < len(words) && (words[i] == "MODE" || words[i] == "OVERRIDE" || words[i] == "NOWAIT") {
+			i++
+		}
+	}
+	if i >= len(words) {
+		return ""
+	}
+	return words[i]
+}
+
+// sqlBlockedError explains which statement was refused and why.
+type sqlBlockedError struct {
+	Tool      string
+	Argument  string
+	Statement int // 1-based; 0 when the whole payload is refused
+	Text      string
+	Reason    string
+}
+
+func (e *sqlBlockedError) Error() string {
+	where := fmt.Sprintf("%s.%s", e.Tool, e.Argument)
+	if e.Statement == 0 {
+		return fmt.Sprintf("SQL guard blocked %s: %s", where, e.Reason)
+	}
+	return fmt.Sprintf("SQL guard blocked %s statement %d: %s: %s",
+		where, e.Statement, e.Reason, abbreviate(e.Text, 80))
+}
+
+// check validates every guarded argument of a call to the upstream tool.
+func (g *sqlGuard) check(tool string, args map[string]interface{}) error {
+	for _, rule := range g.rules {
+		if !matchAny([]string{rule.Tool}, tool) {
+			continue
+		}
+		value, ok := args[rule.Argument]
+		if !ok {
+			continue
+		}
+		text, ok := value.(string)
+		if !ok {
+			return &sqlBlockedError{Tool: tool, Argument: rule.Argument, Reason: "argument is not a string"}
+		}
+		if err := checkReadOnlySQL(text); err != nil {
+			err.Tool, err.Argument = tool, rule.Argument
+			return err
+		}
+	}
+	return nil
+}
+
+// checkReadOnlySQL refuses anything but a single read-only statement.
+func checkReadOnlySQL(text string) *sqlBlockedError {
+	statements, err := splitSQL(text)
+	if err != nil {
+		return &sqlBlockedError{Reason: err.Error()}
+	}
+	if len(statements) == 0 {
+		return &sqlBlockedError{Reason: "no SQL statement found"}
+	}
+	if len(statements) > 1 {
+		return &sqlBlockedError{
+			Statement: 2,
+			Text:      statements[1].text,
+			Reason:    fmt.Sprintf("multi-statement payloads are not allowed (%d statements)", len(statements)),
+		}
+	}
+
+	stmt := statements[0]
+	first := stmt.words[0]
+	if !readOnlyStatements[first] {
+		reason := fmt.Sprintf("%s is not a read-only statement", first)
+		if kind, ok := statementKinds[first]; ok {
+			reason = fmt.Sprintf("%s is a %s statement", first, kind)
+		}
+		return &sqlBlockedError{Statement: 1, Text: stmt.text, Reason: reason}
+	}
+	for _, w := range stmt.words[1:] {
+		if modifyingKeywords[w] {
+			return &sqlBlockedError{
+				Statement: 1,
+				Text:      stmt.text,
+				Reason:    fmt.Sprintf("%s statement contains %s", first, w),
+			}
+		}
+	}
+	if first == "LOCKING" || first == "LOCK" {
+		switch target := lockingTarget(stmt.words); target {
+		case "SELECT", "SEL", "WITH":
+		case "":
+			return &sqlBlockedError{Statement: 1, Text: stmt.text, Reason: "LOCKING modifier is not followed by a statement"}
+		default:
+			return &sqlBlockedError{Statement: 1, Text: stmt.text, Reason: fmt.Sprintf("LOCKING modifier applies to %s, not a query", target)}
+		}
+	}
+	return nil
+}
+
+// sqlStatement is one statement of a script with comments removed.
+type sqlStatement struct {
+	text  string   // statement text with comments replaced by spaces
+	words []string // upper-cased keywords and identifiers outside literals
+}
+
+// splitSQL splits text on semicolons outside string literals, quoted
+// identifiers and comments. Comments are dropped so they cannot hide
+// keywords; unterminated literals, nested comments and MySQL-style
+// executable comments are refused outright.
+func splitSQL(text string) ([]sqlStatement, error) {
+	var (
+		statements []sqlStatement
+		cur        strings.Builder
+		words      []string
+	)
+	flush := func() {
+		if s := strings.TrimSpace(cur.String()); s != "" && len(words) > 0 {
+			statements = append(statements, sqlStatement{text: s, words: words})
+		}
+		cur.Reset()
+		words = nil
+	}
+
+	runes := []rune(text)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		next := rune(0)
+		if i+1 < len(runes) {
+			next = runes[i+1]
+		}
+
+		switch {
+		case r == '-' && next == '-':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+			cur.WriteRune(' ')
+		case r == '/' && next == '*':
+			if i+2 < len(runes) && runes[i+2] == '!' {
+				return nil, fmt.Errorf("executable comment /*! is not allowed")
+			}
+			end := -1
+			for j := i + 2; j+1 < len(runes); j++ {
+				if runes[j] == '/' && runes[j+1] == '*' {
+					return nil, fmt.Errorf("nested comment is not allowed")
+				}
+				if runes[j] == '*' && runes[j+1] == '/' {
+					end = j + 1
+					break
+				}
+			}
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated comment")
+			}
+			i = end
+			cur.WriteRune(' ')
+		case r == '\'' || r == '"':
+			end := -1
+			for j := i + 1; j < len(runes); j++ {
+				if runes[j] != r {
+					continue
+				}
+				if j+1 < len(runes) && runes[j+1] == r {
+					j++ // doubled quote is an escaped quote
+					continue
+				}
+				end = j
+				break
+			}
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated %s", map[rune]string{'\'': "string literal", '"': "quoted identifier"}[r])
+			}
+			cur.WriteString(string(runes[i : end+1]))
+			i = end
+		case r == ';':
+			flush()
+		case isSQLWordRune(r):
+			start := i
+			for i+1 < len(runes) && isSQLWordRune(runes[i+1]) {
+				i++
+			}
+			word := string(runes[start : i+1])
+			cur.WriteString(word)
+			words = append(words, strings.ToUpper(word))
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	flush()
+	return statements, nil
+}
+
+func isSQLWordRune(r rune) bool {
+	return r == '_' || r == '$' || r == '#' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// abbreviate shortens s to at most n runes for error messages.
+func abbreviate(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > n {
+		return string(r[:n-3]) + "..."
+	}
+	return s
+}
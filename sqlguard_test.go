@@ -0,0 +1,94 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckReadOnlySQL(t *testing.T) {
+	tests := []struct {
+		sql     string
+		blocked string // part of the reason, or "" when allowed
+	}{
+		{"SELECT * FROM t", ""},
+		{"sel a, b from t where c = 1;", ""},
+		{"  -- leading comment\nSELECT 1", ""},
+		{"SELECT /* inline */ 1", ""},
+		{"SELECT 'DELETE FROM t; DROP TABLE t' AS s", ""},
+		{`SELECT "update" FROM t`, ""},
+		{"WITH x AS (SELECT 1 AS a) SELECT a FROM x", ""},
+		{"LOCKING ROW FOR ACCESS SELECT * FROM t", ""},
+		{"LOCKING TABLE t FOR ACCESS SELECT * FROM t", ""},
+		{"LOCK ROW FOR ACCESS SEL * FROM t", ""},
+		{"LOCKING TABLE a FOR ACCESS LOCKING TABLE b FOR ACCESS SELECT * FROM a, b", ""},
+		{"LOCKING TABLE db.t IN ACCESS MODE SELECT * FROM db.t", ""},
+		{"LOCK ROW FOR READ NOWAIT WITH x AS (SELECT 1 AS a) SELECT a FROM x", ""},
+		{"LOCKING TABLE t FOR ACCESS DROP TABLE t", "contains DROP"},
+		{"LOCKING ROW FOR ACCESS CALL p()", "contains CALL"},
+		{"LOCKING ROW FOR ACCESS EXEC m", "contains EXEC"},
+		{"LOCKING ROW FOR ACCESS HELP TABLE t", "applies to HELP"},
+		{"LOCKING TABLE t FOR ACCESS", "not followed by a statement"},
+		{"HELP TABLE t", ""},
+		{"SHOW TABLE t", ""},
+		{"EXPLAIN SELECT * FROM t", ""},
+		{"SELECT 1;", ""},
+
+		{"", "no SQL statement"},
+		{"-- only a comment", "no SQL statement"},
+		{"DELETE FROM t", "DELETE is a DML statement"},
+		{"upd t set a = 1", "UPD is a DML statement"},
+		{"DROP TABLE t", "DDL"},
+		{"GRANT SELECT ON t TO u", "DCL"},
+		{"CALL p()", "procedure call"},
+		{"EXEC m", "macro execution"},
+		{"BT", "transaction control"},
+		{"FOO BAR", "FOO is not a read-only statement"},
+		{"SELECT 1; DELETE FROM t", "multi-statement"},
+		{"SELECT 1; SELECT 2", "multi-statement"},
+		{"SELECT 1 -- ;\n; DROP TABLE t", "multi-statement"},
+		{"/* SELECT */ DELETE FROM t", "DELETE is a DML statement"},
+		{"SELECT AND CONSUME TOP 1 * FROM qt", "contains CONSUME"},
+		{"WITH x AS (SELECT 1) CALL p()", "contains CALL"},
+		{"WITH x AS (SELECT 1) EXEC m", "contains EXEC"},
+		{"SELECT * FROM t; CREATE TABLE u (a INT)", "multi-statement"},
+		{"SELECT a FROM t WHERE b = 1 DROP TABLE t", "contains DROP"},
+		{"SELECT REPLACE(a, 'x', 'y') FROM t", ""},
+		{"SELECT * FROM t WHERE a IN (DELETE FROM u)", "contains DELETE"},
+		{"WITH x AS (SELECT 1) INSERT INTO t SELECT * FROM x", "contains INSERT"},
+		{"LOCKING ROW FOR ACCESS UPDATE t SET a = 1", "contains UPDATE"},
+		{"LOCKING TABLE t FOR WRITE DEL FROM t", "contains DEL"},
+		{"SELECT /*! DELETE */ 1", "executable comment"},
+		{"SELECT /* /* */ */ 1", "nested comment"},
+		{"SELECT 1 /* open", "unterminated comment"},
+		{"SELECT 'open", "unterminated string literal"},
+		{`SELECT "open`, "unterminated quoted identifier"},
+	}
+	for _, tt := range tests {
+		err := checkReadOnlySQL(tt.sql)
+		switch {
+		case tt.blocked == "" && err != nil:
+			t.Errorf("%q: blocked: %v", tt.sql, err)
+		case tt.blocked != "" && err == nil:
+			t.Errorf("%q: allowed, want blocked for %q", tt.sql, tt.blocked)
+		case tt.blocked != "" && !strings.Contains(err.Reason, tt.blocked):
+			t.Errorf("%q: reason %q, want %q", tt.sql, err.Reason, tt.blocked)
+		}
+	}
+}
+
+func TestSQLGuardCheck(t *testing.T) {
+	g := &sqlGuard{rules: []sqlGuardRule{{Tool: "query*", Argument: "sql"}}}
+	if err := g.check("query", map[string]interface{}{"sql": "SELECT 1"}); err != nil {
+		t.Errorf("read-only query blocked: %v", err)
+	}
+	if err := g.check("other", map[string]interface{}{"sql": "DROP TABLE t"}); err != nil {
+		t.Errorf("unguarded tool blocked: %v", err)
+	}
+	err := g.check("query_all", map[string]interface{}{"sql": "DROP TABLE t"})
+	if err == nil || !strings.Contains(err.Error(), "query_all.sql statement 1") {
+		t.Errorf("got %v, want a block naming query_all.sql", err)
+	}
+	if err := g.check("query", map[string]interface{}{"sql": 1}); err == nil {
+		t.Error("non-string argument allowed")
+	}
+}
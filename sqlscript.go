@@ -0,0 +1,414 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// sqlConfig names the tool that runs SQL for the sql command and how
+// queries are paged.
+//
+//	"sql": {"tool": "query", "argument": "query", "dialect": "teradata", "pageSize": 10000}
+type sqlConfig struct {
+	Tool     string `json:"tool,omitempty"`
+	Argument string `json:"argument,omitempty"`
+	// Dialect selects the paging syntax: teradata, limit or ansi.
+	Dialect string `json:"dialect,omitempty"`
+	// PageSize is the default row window for -page.
+	PageSize int `json:"pageSize,omitempty"`
+}
+
+// withDefaults fills in the Teradata MCP server's query tool.
+func (c sqlConfig) withDefaults() sqlConfig {
+	if c.Tool == "" {
+		c.Tool = "query"
+	}
+	if c.Argument == "" {
+		c.Argument = "query"
+	}
+	if c.Dialect == "" {
+		c.Dialect = "teradata"
+	}
+	if c.PageSize == 0 {
+		c.PageSize = 10000
+	}
+	return c
+}
+
+// scriptStatement is one statement of a SQL script. BTEQ dot commands are
+// returned as statements with Command set.
+type scriptStatement struct {
+	Text    string
+	Line    int    // 1-based line the statement starts on
+	Command string // upper-cased BTEQ command, such as ".QUIT"
+}
+
+// splitScript splits a SQL script into statements. Semicolons end a
+// statement unless they are inside a string literal, quoted identifier,
+// comment, the BEGIN ... END body of a procedure or the parentheses of a
+// macro. A dot in the first column of a line begins a BTEQ command, which
+// ends at the line break.
+func splitScript(text string) ([]scriptStatement, error) {
+	var (
+		statements []scriptStatement
+		cur        strings.Builder
+		startLine  int
+		line       = 1
+		words      []string
+		depth      int // BEGIN/END nesting inside a procedure body
+		cases      int // open CASE expressions and statements in it
+		parens     int
+	)
+	runes := []rune(text)
+
+	flush := func() {
+		if s := strings.TrimSpace(cur.String()); s != "" && len(words) > 0 {
+			statements = append(statements, scriptStatement{Text: s, Line: startLine})
+		}
+		cur.Reset()
+		words, depth, cases, parens = nil, 0, 0, 0
+	}
+	isCreate := func(kind string) bool {
+		return len(words) >= 2 && (words[0] == "CREATE" || words[0] == "REPLACE") && words[1] == kind
+	}
+	// nextWord returns the upper-cased word after position i, if any
+	nextWord := func(i int) string {
+		for i < len(runes) && unicode.IsSpace(runes[i]) {
+			i++
+		}
+		start := i
+		for i < len(runes) && isSQLWordRune(runes[i]) {
+			i++
+		}
+		return strings.ToUpper(string(runes[start:i]))
+	}
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		next := rune(0)
+		if i+1 < len(runes) {
+			next = runes[i+1]
+		}
+		if cur.Len() == 0 || strings.TrimSpace(cur.String()) == "" {
+			startLine = line
+		}
+
+		switch {
+		case r == '\n':
+			line++
+			cur.WriteRune(r)
+		case r == '.' && (i == 0 || runes[i-1] == '\n') && len(words) == 0 && unicode.IsLetter(next):
+			end := i
+			for end < len(runes) && runes[end] != '\n' {
+				end++
+			}
+			cmd := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(string(runes[i:end])), ";"))
+			name := strings.ToUpper(strings.Fields(cmd)[0])
+			statements = append(statements, scriptStatement{Text: cmd, Line: line, Command: name})
+			cur.Reset()
+			i = end - 1
+		case r == '-' && next == '-':
+			for i < len(runes) && runes[i] != '\n' {
+				cur.WriteRune(runes[i])
+				i++
+			}
+			i--
+		case r == '/' && next == '*':
+			end := -1
+			for j := i + 2; j+1 < len(runes); j++ {
+				if runes[j] == '*' && runes[j+1] == '/' {
+					end = j + 1
+					break
+				}
+			}
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated comment", line)
+			}
+			comment := string(runes[i : end+1])
+			line += strings.Count(comment, "\n")
+			cur.WriteString(comment)
+			i = end
+		case r == '\'' || r == '"':
+			end := -1
+			for j := i + 1; j < len(runes); j++ {
+				if runes[j] != r {
+					continue
+				}
+				if j+1 < len(runes) && runes[j+1] == r {
+					j++
+					continue
+				}
+				end = j
+				break
+			}
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated %s", line, map[rune]string{'\'': "string literal", '"': "quoted identifier"}[r])
+			}
+			literal := string(runes[i : end+1])
+			line += strings.Count(literal, "\n")
+			cur.WriteString(literal)
+			i = end
+		case r == '(' || r == ')':
+			if r == '(' {
+				parens++
+			} else if parens > 0 {
+				parens--
+			}
+			cur.WriteRune(r)
+		case r == ';':
+			if isCreate("PROCEDURE") && depth > 0 || isCreate("MACRO") && parens > 0 {
+				cur.WriteRune(r)
+				continue
+			}
+			flush()
+		case isSQLWordRune(r):
+			start := i
+			for i+1 < len(runes) && isSQLWordRune(runes[i+1]) {
+				i++
+			}
+			word := strings.ToUpper(string(runes[start : i+1]))
+			prev := ""
+			if len(words) > 0 {
+				prev = words[len(words)-1]
+			}
+			cur.WriteString(string(runes[start : i+1]))
+			words = append(words, word)
+			if !isCreate("PROCEDURE") {
+				break
+			}
+			// Only a bare END closes a BEGIN block: END IF, END WHILE and
+			// the like close control statements, and CASE has its own END
+			switch word {
+			case "BEGIN":
+				depth++
+			case "CASE":
+				if prev != "END" {
+					cases++
+				}
+			case "END":
+				switch nextWord(i + 1) {
+				case "IF", "WHILE", "LOOP", "FOR", "REPEAT":
+				case "CASE":
+					if cases > 0 {
+						cases--
+					}
+				default:
+					if cases > 0 {
+						cases--
+					} else if depth > 0 {
+						depth--
+					}
+				}
+			}
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	flush()
+	return statements, nil
+}
+
+// runSQLCommand implements "sql [-f FILE] [flags] [STATEMENT]".
+func runSQLCommand(a *app, url string, args []string) error {
+	fs := flag.NewFlagSet("sql", flag.ExitOnError)
+	file := fs.String("f", "", "Read statements from this script (- for stdin)")
+	keepGoing := fs.Bool("continue", false, "Continue with the next statement after an error")
+	format := fs.String("format", "", "Result format: table, csv, tsv, jsonl or parquet (default from -out, else table)")
+	page := fs.Bool("page", false, "Fetch the result of a single query in row windows")
+	pageSize := fs.Int("page-size", 0, "Rows per page (implies -page; default from config, else 10000)")
+	maxRows := fs.Int("max-rows", 0, "Stop paging after this many rows")
+	orderBy := fs.String("order-by", "", "Unique ordering to cut pages by (default: the query's ORDER BY, else the first column)")
+	dialect := fs.String("dialect", "", "Paging syntax: teradata, limit or ansi (default from config)")
+	outPath := fs.String("out", "", "Write paged rows to this file instead of stdout")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: sql [flags] [-f SCRIPT | STATEMENT]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	var text string
+	switch {
+	case *file == "-":
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		text = string(data)
+	case *file != "":
+		data, err := os.ReadFile(*file)
+		if err != nil {
+			return err
+		}
+		text = string(data)
+	case fs.NArg() > 0:
+		text = strings.Join(fs.Args(), " ")
+	default:
+		fs.Usage()
+		return errors.New("sql: expected -f SCRIPT or a statement")
+	}
+
+	statements, err := splitScript(text)
+	if err != nil {
+		return fmt.Errorf("sql: %w", err)
+	}
+	if len(statements) == 0 {
+		return errors.New("sql: no statements found")
+	}
+
+	sess, err := a.connect(url)
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	cfg := a.cfg.SQL.withDefaults()
+	ctx := context.Background()
+
+	if *page || *pageSize > 0 || *maxRows > 0 {
+		if len(statements) != 1 || statements[0].Command != "" {
+			return errors.New("sql: paging needs exactly one query")
+		}
+		if *dialect != "" {
+			cfg.Dialect = *dialect
+		}
+		if *pageSize > 0 {
+			cfg.PageSize = *pageSize
+		}
+		return runPagedQuery(ctx, sess, cfg, statements[0].Text, *orderBy, *maxRows, *outPath, *format)
+	}
+	if *outPath != "" {
+		return errors.New("sql: -out needs -page")
+	}
+	if *format == "" {
+		*format = "table"
+	}
+
+	failed, ran := 0, 0
+	total := time.Now()
+	for _, stmt := range statements {
+		if stmt.Command != "" {
+			if stmt.Command == ".QUIT" || stmt.Command == ".EXIT" || stmt.Command == ".LOGOFF" {
+				log.Printf("Line %d: %s, stopping", stmt.Line, stmt.Command)
+				break
+			}
+			log.Printf("Line %d: skipping BTEQ command %s", stmt.Line, stmt.Command)
+			continue
+		}
+
+		ran++
+		fmt.Printf("\n-- Statement %d (line %d)\n%s\n\n", ran, stmt.Line, a.redact.Text(stmt.Text))
+		start := time.Now()
+		result, err := sess.CallTool(ctx, cfg.Tool, map[string]interface{}{cfg.Argument: stmt.Text})
+		elapsed := time.Since(start)
+
+		switch {
+		case err != nil:
+			fmt.Printf("Error: %s\n", a.redact.Text(err.Error()))
+		case result.IsError:
+			fmt.Printf("Error: %s\n", a.redact.Text(strings.TrimSpace(resultText(result))))
+			err = errors.New("tool returned an error")
+		default:
+			err = printStatementResult(os.Stdout, *format, a.redact.Result(result))
+		}
+		fmt.Printf("(%s)\n", elapsed.Round(time.Millisecond))
+
+		if err != nil {
+			failed++
+			if !*keepGoing {
+				return fmt.Errorf("sql: statement %d (line %d) failed", ran, stmt.Line)
+			}
+		}
+	}
+
+	log.Printf("%d statements run, %d failed, in %s", ran, failed, time.Since(total).Round(time.Millisecond))
+	if failed > 0 {
+		return fmt.Errorf("sql: %d statements failed", failed)
+	}
+	return nil
+}
+
+// runPagedQuery streams the pages of one query to outPath or stdout.
+func runPagedQuery(ctx context.Context, sess *session, cfg sqlConfig, query, orderBy string, maxRows int, outPath, format string) error {
+	wrap, ok := sqlDialects[cfg.Dialect]
+	if !ok {
+		return fmt.Errorf("sql: unknown dialect %q", cfg.Dialect)
+	}
+	if outPath == "" {
+		outPath = "-"
+		if format == "" {
+			format = "table"
+		}
+	}
+	rw, err := createRowWriter(outPath, format)
+	if err != nil {
+		return err
+	}
+
+	p := &pager{
+		sess:     sess,
+		tool:     cfg.Tool,
+		argument: cfg.Argument,
+		wrap:     wrap,
+		pageSize: cfg.PageSize,
+		maxRows:  maxRows,
+	}
+	rows, err := p.run(ctx, query, orderBy, &redactingRowWriter{rowWriter: rw, r: sess.redact})
+	if cerr := rw.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("sql: %w", err)
+	}
+	if outPath != "-" {
+		log.Printf("Wrote %d rows to %s", rows, outPath)
+	}
+	return nil
+}
+
+// redactingRowWriter applies the text patterns to string cells.
+type redactingRowWriter struct {
+	rowWriter
+	r *redactor
+}
+
+func (w *redactingRowWriter) WriteRows(rows [][]interface{}) error {
+	if w.r.active() {
+		for _, row := range rows {
+			for i, v := range row {
+				if s, ok := v.(string); ok {
+					row[i] = w.r.Text(s)
+				}
+			}
+		}
+	}
+	return w.rowWriter.WriteRows(rows)
+}
+
+// printStatementResult writes row data in format, or the text content
+// when the result holds no rows.
+func printStatementResult(w io.Writer, format string, r *toolResult) error {
+	t, ok := resultTable(r)
+	if !ok {
+		_, err := fmt.Fprintln(w, strings.TrimSpace(resultText(r)))
+		return err
+	}
+	rw, err := newRowWriter(format, w)
+	if err != nil {
+		return err
+	}
+	if err := rw.WriteHeader(t.Columns); err != nil {
+		return err
+	}
+	if err := rw.WriteRows(t.Rows); err != nil {
+		return err
+	}
+	return rw.Close()
+}
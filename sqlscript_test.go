@@ -0,0 +1,100 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitScript(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{"single", "SELECT 1", []string{"SELECT 1"}},
+		{"several", "SELECT 1;\nSELECT 2;", []string{"SELECT 1", "SELECT 2"}},
+		{"empty statements", ";;SELECT 1;;", []string{"SELECT 1"}},
+		{"semicolon in string", "SELECT 'a;b'; SELECT 2", []string{"SELECT 'a;b'", "SELECT 2"}},
+		{"doubled quote", "SELECT 'it''s;'; SELECT 2", []string{"SELECT 'it''s;'", "SELECT 2"}},
+		{"quoted identifier", `SELECT "a;b" FROM t; SELECT 2`, []string{`SELECT "a;b" FROM t`, "SELECT 2"}},
+		{"line comment", "SELECT 1 -- not; here\n; SELECT 2", []string{"SELECT 1 -- not; here", "SELECT 2"}},
+		{"block comment", "SELECT /* a; b */ 1; SELECT 2", []string{"SELECT /* a; b */ 1", "SELECT 2"}},
+		{"comment only", "-- nothing;\n", nil},
+		{
+			"procedure",
+			"REPLACE PROCEDURE p() BEGIN SELECT 1; SELECT 2; END; SELECT 3;",
+			[]string{"REPLACE PROCEDURE p() BEGIN SELECT 1; SELECT 2; END", "SELECT 3"},
+		},
+		{
+			"procedure with END IF",
+			"REPLACE PROCEDURE p() BEGIN IF 1=1 THEN SELECT 1; END IF; SELECT 2; END;",
+			[]string{"REPLACE PROCEDURE p() BEGIN IF 1=1 THEN SELECT 1; END IF; SELECT 2; END"},
+		},
+		{
+			"procedure with loops",
+			"CREATE PROCEDURE p() BEGIN WHILE x < 3 DO SET x = x + 1; END WHILE; L1: LOOP LEAVE L1; END LOOP; FOR r AS c CURSOR FOR SELECT 1 AS a DO SET y = r.a; END FOR; END; SELECT 2",
+			[]string{"CREATE PROCEDURE p() BEGIN WHILE x < 3 DO SET x = x + 1; END WHILE; L1: LOOP LEAVE L1; END LOOP; FOR r AS c CURSOR FOR SELECT 1 AS a DO SET y = r.a; END FOR; END", "SELECT 2"},
+		},
+		{
+			"procedure with CASE",
+			"CREATE PROCEDURE p() BEGIN SET y = CASE WHEN x = 1 THEN 2 END; CASE x WHEN 1 THEN SET y = 3; END CASE; SELECT y; END; SELECT 2",
+			[]string{"CREATE PROCEDURE p() BEGIN SET y = CASE WHEN x = 1 THEN 2 END; CASE x WHEN 1 THEN SET y = 3; END CASE; SELECT y; END", "SELECT 2"},
+		},
+		{
+			"nested blocks",
+			"CREATE PROCEDURE p() BEGIN BEGIN SELECT 1; END; SELECT 2; END; SELECT 3",
+			[]string{"CREATE PROCEDURE p() BEGIN BEGIN SELECT 1; END; SELECT 2; END", "SELECT 3"},
+		},
+		{
+			"macro",
+			"CREATE MACRO m AS ( SELECT 1; SELECT 2; ); EXEC m;",
+			[]string{"CREATE MACRO m AS ( SELECT 1; SELECT 2; )", "EXEC m"},
+		},
+		{
+			"macro with parameters",
+			"REPLACE MACRO m (n INTEGER) AS (SELECT :n; SELECT (:n + 1);); SELECT 2",
+			[]string{"REPLACE MACRO m (n INTEGER) AS (SELECT :n; SELECT (:n + 1);)", "SELECT 2"},
+		},
+		{"BTEQ command", ".LOGON host/user\nSELECT 1;\n.QUIT", []string{".LOGON host/user", "SELECT 1", ".QUIT"}},
+		{"dot inside a line", "SELECT t.a FROM db .t;", []string{"SELECT t.a FROM db .t"}},
+		{"indented dot", "SELECT 1;\n  .5 + 2;", []string{"SELECT 1", ".5 + 2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statements, err := splitScript(tt.script)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, s := range statements {
+				got = append(got, s.Text)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitScriptLinesAndCommands(t *testing.T) {
+	statements, err := splitScript(".SET WIDTH 200\n\nSELECT 1\nFROM t;\n.quit;\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []scriptStatement{
+		{Text: ".SET WIDTH 200", Line: 1, Command: ".SET"},
+		{Text: "SELECT 1\nFROM t", Line: 3},
+		{Text: ".quit", Line: 5, Command: ".QUIT"},
+	}
+	if !reflect.DeepEqual(statements, want) {
+		t.Errorf("got %+v, want %+v", statements, want)
+	}
+}
+
+func TestSplitScriptErrors(t *testing.T) {
+	for _, script := range []string{"SELECT 'open", `SELECT "open`, "SELECT 1 /* open"} {
+		if _, err := splitScript(script); err == nil {
+			t.Errorf("%q: no error for unterminated text", script)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ThinkInAIXYZ/go-mcp/transport"
+)
+
+// streamTransport speaks newline-delimited JSON-RPC over a stream
+// connection, the framing MCP's stdio transport uses, for servers on a
+// Unix socket or a plain TCP port. When the connection drops it tells the
+// mux through its close hook.
+type streamTransport struct {
+	closeHook
+
+	network string // "unix" or "tcp"
+	addr    string
+
+	conn     net.Conn
+	receiver transport.ClientReceiver
+	writeMu  sync.Mutex
+	done     chan struct{}
+	once     sync.Once
+}
+
+func newStreamTransport(network, addr string) *streamTransport {
+	return &streamTransport{network: network, addr: addr, done: make(chan struct{})}
+}
+
+func (t *streamTransport) SetReceiver(r transport.ClientReceiver) {
+	t.receiver = r
+}
+
+func (t *streamTransport) Start() error {
+	conn, err := net.DialTimeout(t.network, t.addr, 30*time.Second)
+	if err != nil {
+		return err
+	}
+	t.conn = conn
+	go t.readLoop()
+	return nil
+}
+
+func (t *streamTransport) readLoop() {
+	// Messages can be large, so read lines without a length limit
+	r := bufio.NewReader(t.conn)
+	for {
+		line, err := r.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 && t.receiver != nil {
+			t.receiver.Receive(context.Background(), line)
+		}
+		if err != nil {
+			select {
+			case <-t.done:
+			default:
+				log.Printf("Connection to %s %s lost: %v", t.network, t.addr, err)
+				t.Close()
+			}
+			t.fire(err)
+			return
+		}
+	}
+}
+
+// Send writes msg as one line. Encoded JSON-RPC messages contain no
+// newlines.
+func (t *streamTransport) Send(ctx context.Context, msg transport.Message) error {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(10 * time.Second)
+	}
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	select {
+	case <-t.done:
+		return errMuxClosed
+	default:
+	}
+	t.conn.SetWriteDeadline(deadline)
+	_, err := t.conn.Write(append(append([]byte(nil), msg...), '\n'))
+	return err
+}
+
+func (t *streamTransport) Close() error {
+	var err error
+	t.once.Do(func() {
+		close(t.done)
+		if t.conn != nil {
+			err = t.conn.Close()
+		}
+	})
+	return err
+}
@@ -0,0 +1,404 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// columnKind is the inferred type of a table column.
+type columnKind string
+
+const (
+	kindNull   columnKind = "null" // no non-null values seen
+	kindInt    columnKind = "int64"
+	kindFloat  columnKind = "float64"
+	kindBool   columnKind = "bool"
+	kindString columnKind = "string"
+)
+
+// column describes one column of a table.
+type column struct {
+	Name string
+	Kind columnKind
+}
+
+// table is row data found in a tool result. Cells hold nil, bool, int64,
+// float64 or string after typing.
+type table struct {
+	Columns []column
+	Rows    [][]interface{}
+}
+
+// rowKeys are the object fields searched for row data, in order. Other
+// fields holding an array of objects are tried after these.
+var rowKeys = []string{"results", "rows", "data", "records", "items"}
+
+// orderedObject is a JSON object that remembers its key order, so columns
+// come out in the order the server sent them.
+type orderedObject struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+// decodeOrdered reads one JSON value from dec. Objects decode to
+// *orderedObject and numbers to json.Number.
+func decodeOrdered(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		return tok, nil
+	}
+	switch delim {
+	case '{':
+		obj := &orderedObject{values: make(map[string]interface{})}
+		for dec.More() {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			key, _ := keyTok.(string)
+			value, err := decodeOrdered(dec)
+			if err != nil {
+				return nil, err
+			}
+			if _, dup := obj.values[key]; !dup {
+				obj.keys = append(obj.keys, key)
+			}
+			obj.values[key] = value
+		}
+		_, err := dec.Token()
+		return obj, err
+	case '[':
+		arr := []interface{}{}
+		for dec.More() {
+			value, err := decodeOrdered(dec)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, value)
+		}
+		_, err := dec.Token()
+		return arr, err
+	}
+	return nil, fmt.Errorf("unexpected %v", delim)
+}
+
+// plainJSON converts an ordered value back to plain Go JSON values.
+func plainJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case *orderedObject:
+		out := make(map[string]interface{}, len(v.keys))
+		for _, k := range v.keys {
+			out[k] = plainJSON(v.values[k])
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = plainJSON(item)
+		}
+		return out
+	}
+	return v
+}
+
+// parseTable looks for row data in a JSON document.
+func parseTable(data []byte) (*table, bool) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || (data[0] != '{' && data[0] != '[') {
+		return nil, false
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	v, err := decodeOrdered(dec)
+	if err != nil {
+		return nil, false
+	}
+	return findTable(v)
+}
+
+// findTable accepts an array of objects, an object holding one, or an
+// object with parallel "columns" and "rows" arrays.
+func findTable(v interface{}) (*table, bool) {
+	switch v := v.(type) {
+	case []interface{}:
+		return objectRows(v)
+	case *orderedObject:
+		if t, ok := columnRows(v); ok {
+			return t, true
+		}
+		for _, key := range rowKeys {
+			if arr, ok := v.values[key].([]interface{}); ok {
+				if t, ok := objectRows(arr); ok {
+					return t, true
+				}
+			}
+		}
+		for _, key := range v.keys {
+			if arr, ok := v.values[key].([]interface{}); ok {
+				if t, ok := objectRows(arr); ok {
+					return t, true
+				}
+			}
+		}
+	}
+	return nil, false
+}
+
+// objectRows builds a table from an array of objects. Columns are the
+// union of keys in first-seen order.
+func objectRows(arr []interface{}) (*table, bool) {
+	if len(arr) == 0 {
+		return nil, false
+	}
+	index := make(map[string]int)
+	var names []string
+	for _, item := range arr {
+		obj, ok := item.(*orderedObject)
+		if !ok {
+			return nil, false
+		}
+		for _, k := range obj.keys {
+			if _, seen := index[k]; !seen {
+				index[k] = len(names)
+				names = append(names, k)
+			}
+		}
+	}
+
+	t := &table{}
+	for _, name := range names {
+		t.Columns = append(t.Columns, column{Name: name})
+	}
+	for _, item := range arr {
+		obj := item.(*orderedObject)
+		row := make([]interface{}, len(names))
+		for k, value := range obj.values {
+			row[index[k]] = value
+		}
+		t.Rows = append(t.Rows, row)
+	}
+	t.inferTypes()
+	return t, true
+}
+
+// columnRows builds a table from {"columns": [...], "rows": [[...], ...]}.
+// Columns may be names or objects with a "name" field.
+func columnRows(obj *orderedObject) (*table, bool) {
+	cols, ok := obj.values["columns"].([]interface{})
+	if !ok {
+		return nil, false
+	}
+	rows, ok := obj.values["rows"].([]interface{})
+	if !ok {
+		return nil, false
+	}
+
+	t := &table{}
+	for _, c := range cols {
+		switch c := c.(type) {
+		case string:
+			t.Columns = append(t.Columns, column{Name: c})
+		case *orderedObject:
+			name, _ := c.values["name"].(string)
+			t.Columns = append(t.Columns, column{Name: name})
+		default:
+			return nil, false
+		}
+	}
+	for _, r := range rows {
+		cells, ok := r.([]interface{})
+		if !ok || len(cells) != len(t.Columns) {
+			return nil, false
+		}
+		t.Rows = append(t.Rows, append([]interface{}{}, cells...))
+	}
+	t.inferTypes()
+	return t, true
+}
+
+// inferTypes picks the narrowest kind that holds every value of each
+// column and converts the cells to it.
+func (t *table) inferTypes() {
+	for i := range t.Columns {
+		kind := kindNull
+		for _, row := range t.Rows {
+			kind = widenKind(kind, cellKind(row[i]))
+		}
+		t.Columns[i].Kind = kind
+		for _, row := range t.Rows {
+			row[i] = convertCell(row[i], kind)
+		}
+	}
+}
+
+func cellKind(v interface{}) columnKind {
+	switch v := v.(type) {
+	case nil:
+		return kindNull
+	case bool:
+		return kindBool
+	case json.Number:
+		if _, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			return kindInt
+		}
+		return kindFloat
+	}
+	return kindString
+}
+
+func widenKind(a, b columnKind) columnKind {
+	switch {
+	case a == b || b == kindNull:
+		return a
+	case a == kindNull:
+		return b
+	case (a == kindInt && b == kindFloat) || (a == kindFloat && b == kindInt):
+		return kindFloat
+	}
+	return kindString
+}
+
+func convertCell(v interface{}, kind columnKind) interface{} {
+	if v == nil {
+		return nil
+	}
+	switch kind {
+	case kindInt:
+		n, _ := strconv.ParseInt(string(v.(json.Number)), 10, 64)
+		return n
+	case kindFloat:
+		f, _ := v.(json.Number).Float64()
+		return f
+	case kindBool:
+		return v
+	}
+	switch v := v.(type) {
+	case string:
+		return v
+	case json.Number:
+		return string(v)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	data, _ := json.Marshal(plainJSON(v))
+	return string(data)
+}
+
+// formatCell renders a typed cell as text. Nulls render as "".
+func formatCell(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case string:
+		return v
+	}
+	return fmt.Sprint(v)
+}
+
+// resultTable returns the first table found in a result, looking at the
+// structured content before the text items.
+func resultTable(r *toolResult) (*table, bool) {
+	if len(r.StructuredContent) > 0 {
+		if t, ok := parseTable(r.StructuredContent); ok {
+			return t, true
+		}
+	}
+	for _, c := range r.Content {
+		if c.Type != "text" {
+			continue
+		}
+		if t, ok := parseTable([]byte(c.Text)); ok {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+// maxCellWidth caps the width of a rendered column.
+const maxCellWidth = 40
+
+// renderTable writes t as an aligned text table. Numbers are right
+// aligned and nulls shown as NULL.
+func renderTable(w io.Writer, t *table) error {
+	widths := make([]int, len(t.Columns))
+	cell := func(row []interface{}, i int) string {
+		if row[i] == nil {
+			return "NULL"
+		}
+		s := strings.Join(strings.Fields(formatCell(row[i])), " ")
+		if utf8.RuneCountInString(s) > maxCellWidth {
+			s = string([]rune(s)[:maxCellWidth-1]) + "…"
+		}
+		return s
+	}
+	for i, c := range t.Columns {
+		widths[i] = utf8.RuneCountInString(c.Name)
+	}
+	for _, row := range t.Rows {
+		for i := range t.Columns {
+			if n := utf8.RuneCountInString(cell(row, i)); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+
+	pad := func(s string, width int, right bool) string {
+		gap := strings.Repeat(" ", width-utf8.RuneCountInString(s))
+		if right {
+			return gap + s
+		}
+		return s + gap
+	}
+	var b strings.Builder
+	for i, c := range t.Columns {
+		if i > 0 {
+			b.WriteString("  ")
+		}
+		b.WriteString(pad(c.Name, widths[i], false))
+	}
+	header := strings.TrimRight(b.String(), " ")
+	b.Reset()
+	b.WriteString(header)
+	b.WriteString("\n")
+	for i := range t.Columns {
+		if i > 0 {
+			b.WriteString("  ")
+		}
+		b.WriteString(strings.Repeat("-", widths[i]))
+	}
+	b.WriteString("\n")
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return err
+	}
+
+	for _, row := range t.Rows {
+		b.Reset()
+		for i, c := range t.Columns {
+			if i > 0 {
+				b.WriteString("  ")
+			}
+			numeric := c.Kind == kindInt || c.Kind == kindFloat
+			b.WriteString(pad(cell(row, i), widths[i], numeric))
+		}
+		if _, err := io.WriteString(w, strings.TrimRight(b.String(), " ")+"\n"); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "(%d rows)\n", len(t.Rows))
+	return err
+}
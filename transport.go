@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/ThinkInAIXYZ/go-mcp/transport"
+)
+
+// tlsConfig configures TLS for https:// and wss:// servers.
+//
+//	"tls": {"caFile": "internal-ca.pem", "certFile": "me.pem", "keyFile": "me-key.pem"}
+type tlsConfig struct {
+	// CAFile is a PEM bundle trusted in addition to the system roots.
+	CAFile string `json:"caFile,omitempty"`
+	// CertFile and KeyFile are a client certificate for mutual TLS.
+	CertFile string `json:"certFile,omitempty"`
+	KeyFile  string `json:"keyFile,omitempty"`
+	// ServerName overrides the name the server certificate must carry.
+	ServerName string `json:"serverName,omitempty"`
+	// InsecureSkipVerify accepts any server certificate.
+	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty"`
+}
+
+// merge returns c with the fields set in o taking precedence.
+func (c tlsConfig) merge(o tlsConfig) tlsConfig {
+	if o.CAFile != "" {
+		c.CAFile = o.CAFile
+	}
+	if o.CertFile != "" {
+		c.CertFile, c.KeyFile = o.CertFile, o.KeyFile
+	}
+	if o.ServerName != "" {
+		c.ServerName = o.ServerName
+	}
+	c.InsecureSkipVerify = c.InsecureSkipVerify || o.InsecureSkipVerify
+	return c
+}
+
+// build returns the crypto/tls configuration, or nil for the defaults.
+func (c tlsConfig) build() (*tls.Config, error) {
+	if c == (tlsConfig{}) {
+		return nil, nil
+	}
+	cfg := &tls.Config{ServerName: c.ServerName, InsecureSkipVerify: c.InsecureSkipVerify}
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("tls ca: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("tls ca: no certificates in %s", c.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+	if c.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("tls client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// headerList collects repeated -header "Name: value" flags.
+type headerList map[string]string
+
+func (h headerList) String() string { return fmt.Sprint(len(h), " headers") }
+
+func (h headerList) Set(s string) error {
+	name, value, ok := strings.Cut(s, ":")
+	if !ok || strings.TrimSpace(name) == "" {
+		return fmt.Errorf("expected \"Name: value\", got %q", s)
+	}
+	h[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	return nil
+}
+
+// dialOptions is how to reach a server beyond its URL.
+type dialOptions struct {
+	headers    http.Header
+	tls        *tls.Config
+	onNotify   notificationHandler
+	middleware []middleware
+}
+
+// dialOptions returns the headers and TLS settings for url: those of the
+// configured server with that URL, overridden by the -header and TLS
+// flags. Header values may name environment variables as $VAR.
+func (a *app) dialOptions(url string) (dialOptions, error) {
+	headers := map[string]string{}
+	var tc tlsConfig
+	for _, srv := range a.cfg.Servers {
+		if srv.URL == url {
+			for k, v := range srv.Headers {
+				headers[k] = v
+			}
+			tc = srv.TLS
+			break
+		}
+	}
+	for k, v := range a.headers {
+		headers[k] = v
+	}
+	tc = tc.merge(a.tls)
+
+	chain, err := a.middlewareChain()
+	if err != nil {
+		return dialOptions{}, err
+	}
+	opts := dialOptions{headers: http.Header{}, middleware: chain}
+	for k, v := range headers {
+		opts.headers.Set(k, os.ExpandEnv(v))
+	}
+	if opts.tls, err = tc.build(); err != nil {
+		return dialOptions{}, err
+	}
+	return opts, nil
+}
+
+// newTransport returns the transport for rawURL's scheme:
+//
+//	http://, https://        SSE
+//	ws://, wss://            WebSocket
+//	unix:///run/mcp.sock     newline-delimited JSON-RPC over a Unix socket
+//	tcp://host:port          newline-delimited JSON-RPC over TCP
+//	http+unix:///run/mcp.sock/sse
+//	                         SSE over a Unix socket
+func newTransport(rawURL string, opts dialOptions) (transport.ClientTransport, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "ws", "wss":
+		return newWSTransport(rawURL, opts), nil
+	case "http", "https":
+		client := &http.Client{Transport: &headerTransport{base: httpTransport(opts.tls, ""), headers: opts.headers}}
+		return transport.NewSSEClientTransport(rawURL, transport.WithSSEClientOptionHTTPClient(client))
+	case "http+unix":
+		socket, httpURL, err := splitUnixURL(u)
+		if err != nil {
+			return nil, err
+		}
+		client := &http.Client{Transport: &headerTransport{base: httpTransport(nil, socket), headers: opts.headers}}
+		return transport.NewSSEClientTransport(httpURL, transport.WithSSEClientOptionHTTPClient(client))
+	case "unix":
+		path := u.Path
+		if path == "" {
+			path = u.Opaque
+		}
+		if path == "" {
+			return nil, fmt.Errorf("%s: missing socket path", rawURL)
+		}
+		return newStreamTransport("unix", path), nil
+	case "tcp":
+		if u.Port() == "" {
+			return nil, fmt.Errorf("%s: missing port", rawURL)
+		}
+		return newStreamTransport("tcp", u.Host), nil
+	}
+	return nil, fmt.Errorf("unsupported URL scheme %q", u.Scheme)
+}
+
+// splitUnixURL takes an http+unix URL apart into the socket path, which
+// runs up to the first path element ending in ".sock", and the http URL
+// to request over it.
+func splitUnixURL(u *url.URL) (socket, httpURL string, err error) {
+	i := strings.Index(u.Path, ".sock")
+	if u.Host != "" || i < 0 {
+		return "", "", fmt.Errorf("%s: expected http+unix:///path/to.sock/http/path", u)
+	}
+	h := *u
+	h.Scheme, h.Host, h.Path, h.RawPath = "http", "localhost", u.Path[i+len(".sock"):], ""
+	if h.Path == "" {
+		h.Path = "/"
+	}
+	return u.Path[:i+len(".sock")], h.String(), nil
+}
+
+// httpTransport returns an HTTP transport using cfg for TLS, connecting
+// through the Unix socket when one is given.
+func httpTransport(cfg *tls.Config, socket string) http.RoundTripper {
+	if cfg == nil && socket == "" {
+		return http.DefaultTransport
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.TLSClientConfig = cfg
+	if socket != "" {
+		t.Proxy = nil
+		t.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		}
+	}
+	return t
+}
+
+// headerTransport adds fixed headers to every request.
+type headerTransport struct {
+	base    http.RoundTripper
+	headers http.Header
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if len(t.headers) > 0 {
+		req = req.Clone(req.Context())
+		for k, v := range t.headers {
+			req.Header[k] = v
+		}
+	}
+	return t.base.RoundTrip(req)
+}
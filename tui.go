@@ -0,0 +1,182 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// terminal is the TTY in raw mode on the alternate screen.
+type terminal struct {
+	in, out *os.File
+	state   *term.State
+}
+
+func openTerminal() (*terminal, error) {
+	t := &terminal{in: os.Stdin, out: os.Stdout}
+	if !term.IsTerminal(int(t.in.Fd())) || !term.IsTerminal(int(t.out.Fd())) {
+		return nil, errors.New("the inspector needs a terminal")
+	}
+	state, err := term.MakeRaw(int(t.in.Fd()))
+	if err != nil {
+		return nil, err
+	}
+	t.state = state
+	// Switch to the alternate screen and hide the cursor
+	t.out.WriteString("\x1b[?1049h\x1b[?25l")
+	return t, nil
+}
+
+// Close restores the screen and the terminal mode.
+func (t *terminal) Close() error {
+	t.out.WriteString("\x1b[?25h\x1b[?1049l")
+	return term.Restore(int(t.in.Fd()), t.state)
+}
+
+// size returns the screen width and height.
+func (t *terminal) size() (int, int) {
+	w, h, err := term.GetSize(int(t.out.Fd()))
+	if err != nil || w <= 0 || h <= 0 {
+		return 80, 24
+	}
+	return w, h
+}
+
+// draw replaces the screen with lines, which must already fit its width.
+func (t *terminal) draw(lines []string) {
+	var b strings.Builder
+	b.WriteString("\x1b[H")
+	for i, line := range lines {
+		if i > 0 {
+			b.WriteString("\r\n")
+		}
+		b.WriteString(line)
+		b.WriteString("\x1b[K")
+	}
+	b.WriteString("\x1b[J")
+	t.out.WriteString(b.String())
+}
+
+// readKeys sends key presses to keys until reading fails.
+func (t *terminal) readKeys(keys chan<- key) {
+	buf := make([]byte, 256)
+	for {
+		n, err := t.in.Read(buf)
+		if err != nil {
+			close(keys)
+			return
+		}
+		for _, k := range parseKeys(buf[:n]) {
+			keys <- k
+		}
+	}
+}
+
+// keyCode identifies a special key; keyRune is a printable character.
+type keyCode int
+
+const (
+	keyRune keyCode = iota
+	keyUp
+	keyDown
+	keyLeft
+	keyRight
+	keyPgUp
+	keyPgDn
+	keyHome
+	keyEnd
+	keyEnter
+	keyTab
+	keyBackTab
+	keyBackspace
+	keyEsc
+	keyCtrlC
+)
+
+type key struct {
+	code keyCode
+	r    rune
+}
+
+// escapeKeys maps the escape sequences of common terminals.
+var escapeKeys = map[string]keyCode{
+	"[A": keyUp, "[B": keyDown, "[C": keyRight, "[D": keyLeft,
+	"OA": keyUp, "OB": keyDown, "OC": keyRight, "OD": keyLeft,
+	"[5~": keyPgUp, "[6~": keyPgDn,
+	"[H": keyHome, "[F": keyEnd, "[1~": keyHome, "[4~": keyEnd,
+	"[Z": keyBackTab,
+}
+
+// parseKeys decodes the bytes of one read into key presses.
+func parseKeys(buf []byte) []key {
+	var keys []key
+	for len(buf) > 0 {
+		b := buf[0]
+		switch {
+		case b == 0x1b:
+			if len(buf) == 1 {
+				return append(keys, key{code: keyEsc})
+			}
+			// Longest known sequence first
+			matched := false
+			for n := 3; n >= 2; n-- {
+				if len(buf) > n {
+					if code, ok := escapeKeys[string(buf[1:1+n])]; ok {
+						keys = append(keys, key{code: code})
+						buf = buf[1+n:]
+						matched = true
+						break
+					}
+				}
+			}
+			if !matched {
+				keys = append(keys, key{code: keyEsc})
+				buf = buf[1:]
+			}
+			continue
+		case b == '\r' || b == '\n':
+			keys = append(keys, key{code: keyEnter})
+		case b == '\t':
+			keys = append(keys, key{code: keyTab})
+		case b == 0x7f || b == 0x08:
+			keys = append(keys, key{code: keyBackspace})
+		case b == 0x03:
+			keys = append(keys, key{code: keyCtrlC})
+		case b < 0x20:
+			// Ignore other control characters
+		default:
+			r, size := utf8.DecodeRune(buf)
+			keys = append(keys, key{code: keyRune, r: r})
+			buf = buf[size:]
+			continue
+		}
+		buf = buf[1:]
+	}
+	return keys
+}
+
+// fit pads or cuts s to exactly width characters.
+func fit(s string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	s = strings.Map(func(r rune) rune {
+		if r < 0x20 {
+			return ' '
+		}
+		return r
+	}, s)
+	n := utf8.RuneCountInString(s)
+	if n > width {
+		return string([]rune(s)[:width-1]) + "…"
+	}
+	return s + strings.Repeat(" ", width-n)
+}
+
+// Text styles
+func reverse(s string) string { return "\x1b[7m" + s + "\x1b[0m" }
+func bold(s string) string    { return "\x1b[1m" + s + "\x1b[0m" }
+func dim(s string) string     { return "\x1b[2m" + s + "\x1b[0m" }
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// watchTarget is what the watch command repeats: the -tool call, the
+// -resource read or the -list listing given before the command.
+type watchTarget struct {
+	tool     string
+	resource string
+	list     string
+	run      func(ctx context.Context, sess *session, w io.Writer) (fanValue, error)
+}
+
+func (t watchTarget) String() string {
+	switch {
+	case t.resource != "":
+		return "resource " + t.resource
+	case t.tool != "":
+		return "tool " + t.tool
+	}
+	return t.list + " list"
+}
+
+// wakeOn reports whether the notification means the target may have
+// changed.
+func (t watchTarget) wakeOn(n rpcNotification) bool {
+	switch {
+	case t.resource != "":
+		var p struct {
+			URI string `json:"uri"`
+		}
+		json.Unmarshal(n.Params, &p)
+		return n.Method == "notifications/resources/updated" && p.URI == t.resource
+	case t.tool != "":
+		return false
+	}
+	return n.Method == "notifications/"+t.list+"/list_changed"
+}
+
+// runWatchCommand implements "watch [flags]". It repeats the target at an
+// interval, and also whenever the server announces a relevant change if it
+// supports that, until an exit condition is met or it is interrupted.
+func runWatchCommand(a *app, url string, args []string, target watchTarget) error {
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	interval := fs.Duration("interval", 5*time.Second, "Time between runs; 0 waits for server notifications only")
+	count := fs.Int("count", 0, "Stop after this many runs")
+	untilEqual := fs.String("until-equal", "", "Stop when the result text equals this")
+	untilMatch := fs.String("until-match", "", "Stop when the result text matches this regular expression")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: [-tool NAME -arg K=V | -resource URI | -list KIND] watch [flags]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	var match *regexp.Regexp
+	if *untilMatch != "" {
+		var err error
+		if match, err = regexp.Compile(*untilMatch); err != nil {
+			return fmt.Errorf("watch: -until-match: %w", err)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	sess, err := a.connect(url)
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	// Every run must reach the server
+	a.results = nil
+	a.catalogs.refresh = true
+
+	// Use notifications where the server offers them
+	notes := sess.notifications()
+	notify := false
+	if caps, ok := sess.capabilities(); ok {
+		switch {
+		case target.resource != "":
+			if caps.Resources == nil || !caps.Resources.Subscribe {
+				break
+			}
+			if err := sess.SubscribeResource(ctx, target.resource); err != nil {
+				log.Printf("Failed to subscribe to %s, polling instead: %v", target.resource, err)
+			} else {
+				notify = true
+			}
+		case target.tool != "":
+			// Tool results have no change notifications
+		default:
+			notify = target.list == "tools" && caps.Tools != nil && caps.Tools.ListChanged ||
+				target.list == "resources" && caps.Resources != nil && caps.Resources.ListChanged ||
+				target.list == "prompts" && caps.Prompts != nil && caps.Prompts.ListChanged
+		}
+	}
+	if *interval <= 0 && !notify {
+		return fmt.Errorf("watch: the server sends no change notifications for the %s; set -interval", target)
+	}
+	if notify && a.verbose {
+		log.Printf("Watching %s for change notifications", target)
+	}
+
+	var tick <-chan time.Time
+	if *interval > 0 {
+		ticker := time.NewTicker(*interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	tty := isTerminal(os.Stdout)
+	var prev *fanValue
+	for n := 1; ; n++ {
+		if target.tool == "" && target.resource == "" && target.list == "tools" {
+			sess.dropTools()
+		}
+		var buf bytes.Buffer
+		value, err := target.run(ctx, sess, &buf)
+		if errors.Is(err, context.Canceled) {
+			return nil
+		}
+		if err != nil {
+			msg := a.redact.Text(err.Error())
+			fmt.Fprintf(&buf, "Error: %s\n", msg)
+			value = fanValue{Lines: []string{"error: " + msg}}
+		}
+
+		now := time.Now().Format("15:04:05")
+		switch {
+		case tty:
+			// Redraw in place
+			fmt.Printf("\x1b[H\x1b[2J%s: every %s  run %d  %s\n", target, describeInterval(*interval, notify), n, now)
+			os.Stdout.Write(buf.Bytes())
+		case prev == nil:
+			fmt.Printf("[%s] %s\n", now, target)
+			os.Stdout.Write(buf.Bytes())
+		default:
+			if changes := watchChanges(*prev, value); len(changes) > 0 {
+				fmt.Printf("[%s] %s changed\n", now, target)
+				for _, line := range changes {
+					fmt.Println(line)
+				}
+			}
+		}
+		prev = &value
+
+		text := watchText(value, buf.String())
+		switch {
+		case *untilEqual != "" && strings.TrimSpace(text) == strings.TrimSpace(*untilEqual):
+			log.Printf("Result equals %q after %d runs", *untilEqual, n)
+			return nil
+		case match != nil && match.MatchString(text):
+			log.Printf("Result matches %s after %d runs", *untilMatch, n)
+			return nil
+		case *count > 0 && n >= *count:
+			return nil
+		}
+
+		// Wait for the next tick or a relevant notification
+	wait:
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-tick:
+				break wait
+			case note := <-notes:
+				if target.wakeOn(note) {
+					break wait
+				}
+			}
+		}
+	}
+}
+
+func describeInterval(interval time.Duration, notify bool) string {
+	switch {
+	case interval <= 0:
+		return "change notification"
+	case notify:
+		return interval.String() + " or change notification"
+	}
+	return interval.String()
+}
+
+// watchText is the text exit conditions are tested against: the result
+// for calls and reads, the printed listing for lists.
+func watchText(v fanValue, output string) string {
+	if v.Catalog != nil {
+		return output
+	}
+	return strings.Join(v.Lines, "\n")
+}
+
+// watchChanges describes how cur differs from prev: entries added to,
+// removed from or changed in a catalog, or a line diff of a result.
+func watchChanges(prev, cur fanValue) []string {
+	if prev.Catalog == nil || cur.Catalog == nil {
+		return diffLines(prev.Lines, cur.Lines)
+	}
+	var changes []string
+	for name, def := range cur.Catalog {
+		old, ok := prev.Catalog[name]
+		switch {
+		case !ok:
+			changes = append(changes, "+ "+name)
+		case old != def:
+			changes = append(changes, "~ "+name)
+		}
+	}
+	for name := range prev.Catalog {
+		if _, ok := cur.Catalog[name]; !ok {
+			changes = append(changes, "- "+name)
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i][2:] < changes[j][2:] })
+	return changes
+}
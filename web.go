@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+//go:embed web
+var webAssets embed.FS
+
+// webEvent is one entry of the web inspector's message log.
+type webEvent struct {
+	Time string `json:"time"`
+	Kind string `json:"kind"` // "send", "recv" or "log"
+	Text string `json:"text"`
+}
+
+// webHub fans the message log out to the connected browsers and keeps the
+// recent entries for browsers that connect later.
+type webHub struct {
+	mu      sync.Mutex
+	recent  [][]byte
+	clients map[chan []byte]bool
+}
+
+// webBacklog is how many log entries a new browser is sent.
+const webBacklog = 200
+
+func (h *webHub) publish(kind, text string) {
+	data, _ := json.Marshal(webEvent{Time: time.Now().Format("15:04:05.000"), Kind: kind, Text: text})
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.recent = append(h.recent, data)
+	if len(h.recent) > webBacklog {
+		h.recent = h.recent[len(h.recent)-webBacklog:]
+	}
+	for ch := range h.clients {
+		select {
+		case ch <- data:
+		default:
+			// A browser that can't keep up misses entries
+		}
+	}
+}
+
+// Write lets the hub take log output.
+func (h *webHub) Write(p []byte) (int, error) {
+	h.publish("log", strings.TrimRight(string(p), "\n"))
+	return len(p), nil
+}
+
+func (h *webHub) subscribe() (chan []byte, [][]byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ch := make(chan []byte, 64)
+	h.clients[ch] = true
+	return ch, append([][]byte(nil), h.recent...)
+}
+
+func (h *webHub) unsubscribe(ch chan []byte) {
+	h.mu.Lock()
+	delete(h.clients, ch)
+	h.mu.Unlock()
+}
+
+// webInspector serves the web inspector's API for one session.
+type webInspector struct {
+	sess  *session
+	url   string
+	token string
+	hub   *webHub
+}
+
+// runWebInspector implements "inspect -web". It serves the embedded UI
+// and a JSON API on addr, guarded by a random token, until interrupted.
+func runWebInspector(a *app, url, addr string) error {
+	hub := &webHub{clients: make(map[chan []byte]bool)}
+
+	// The inspector shows the server's messages, so it connects directly
+	a.noDaemon = true
+	a.use(observe(func(outgoing bool, msg []byte) {
+		kind := "recv"
+		if outgoing {
+			kind = "send"
+		}
+		hub.publish(kind, a.redact.JSONText(string(msg)))
+	}))
+	// Calls are confirmed in the browser
+	assumeYes := a.confirm.assumeYes
+	a.confirm.assumeYes = true
+
+	log.SetOutput(io.MultiWriter(redactingWriter{w: os.Stderr, r: a.redact}, redactingWriter{w: hub, r: a.redact}))
+	defer log.SetOutput(redactingWriter{w: os.Stderr, r: a.redact})
+
+	sess, err := a.connect(url)
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return err
+	}
+	w := &webInspector{sess: sess, url: url, token: hex.EncodeToString(buf), hub: hub}
+
+	assets, _ := fs.Sub(webAssets, "web")
+	mux := http.NewServeMux()
+	mux.Handle("/", http.FileServer(http.FS(assets)))
+	mux.HandleFunc("/api/catalog", w.guard(w.handleCatalog))
+	mux.HandleFunc("/api/call", w.guard(func(rw http.ResponseWriter, r *http.Request) {
+		w.handleCall(rw, r, assumeYes)
+	}))
+	mux.HandleFunc("/api/ws", w.guard(w.handleSocket))
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("inspect: %w", err)
+	}
+	if host, _, _ := net.SplitHostPort(addr); host != "" && host != "localhost" {
+		if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+			log.Printf("Warning: the web inspector listens on %s, not just this machine; anyone with the link can call tools", host)
+		}
+	}
+	server := &http.Server{Handler: mux}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdown)
+	}()
+
+	fmt.Printf("Web inspector for %s at http://%s/?token=%s\n", url, ln.Addr(), w.token)
+	if err := server.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// guard rejects API requests without the token. Browsers can't set
+// headers on websockets, so it may also come as a query parameter.
+func (w *webInspector) guard(h http.HandlerFunc) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get("X-Inspector-Token")
+		if token == "" {
+			token = r.URL.Query().Get("token")
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(w.token)) != 1 {
+			writeJSON(rw, http.StatusUnauthorized, map[string]string{"error": "missing or wrong token"})
+			return
+		}
+		h(rw, r)
+	}
+}
+
+func writeJSON(rw http.ResponseWriter, status int, v interface{}) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	json.NewEncoder(rw).Encode(v)
+}
+
+func (w *webInspector) handleCatalog(rw http.ResponseWriter, r *http.Request) {
+	out := map[string]interface{}{
+		"server": w.url,
+		"info":   w.sess.serverVersion(),
+	}
+	errs := map[string]string{}
+	var err error
+	if out["tools"], err = w.sess.ListTools(r.Context()); err != nil {
+		errs["tools"] = err.Error()
+	}
+	if out["resources"], err = w.sess.ListResources(r.Context()); err != nil {
+		errs["resources"] = err.Error()
+	}
+	if out["prompts"], err = w.sess.ListPrompts(r.Context()); err != nil {
+		errs["prompts"] = err.Error()
+	}
+	out["errors"] = errs
+	writeJSON(rw, http.StatusOK, out)
+}
+
+// webCall is a request from the browser to call a tool, read a resource
+// or get a prompt.
+type webCall struct {
+	Kind      string                 `json:"kind"`
+	Name      string                 `json:"name"`
+	Args      map[string]interface{} `json:"args"`
+	Confirmed bool                   `json:"confirmed"`
+}
+
+func (w *webInspector) handleCall(rw http.ResponseWriter, r *http.Request, assumeYes bool) {
+	if r.Method != http.MethodPost {
+		writeJSON(rw, http.StatusMethodNotAllowed, map[string]string{"error": "use POST"})
+		return
+	}
+	var call webCall
+	if err := json.NewDecoder(r.Body).Decode(&call); err != nil {
+		writeJSON(rw, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	ctx := r.Context()
+	redact := w.sess.redact
+	if call.Kind == "tool" && !call.Confirmed && !assumeYes && confirmRequired(ctx, w.sess, call.Name) {
+		writeJSON(rw, http.StatusConflict, map[string]string{
+			"confirm": fmt.Sprintf("Tool %s is marked destructive or needs confirmation. Call it?", call.Name),
+		})
+		return
+	}
+
+	start := time.Now()
+	var result interface{}
+	var err error
+	switch call.Kind {
+	case "tool":
+		var res *toolResult
+		if res, err = w.sess.CallTool(ctx, call.Name, call.Args); err == nil {
+			result = redact.Result(res)
+		}
+	case "resource":
+		var contents []resourceContent
+		if contents, err = w.sess.ReadResource(ctx, call.Name); err == nil {
+			result = redact.Resources(contents)
+		}
+	case "prompt":
+		args := map[string]string{}
+		for k, v := range call.Args {
+			args[k] = fmt.Sprint(v)
+		}
+		var p *promptResult
+		if p, err = w.sess.GetPrompt(ctx, call.Name, args); err == nil {
+			data, _ := json.Marshal(p)
+			result = json.RawMessage(redact.JSONText(string(data)))
+		}
+	default:
+		writeJSON(rw, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("unknown kind %q", call.Kind)})
+		return
+	}
+
+	out := map[string]interface{}{"durationMs": time.Since(start).Milliseconds()}
+	if err != nil {
+		out["error"] = redact.Text(err.Error())
+	} else {
+		out["result"] = result
+	}
+	writeJSON(rw, http.StatusOK, out)
+}
+
+// handleSocket streams the message log to a browser.
+func (w *webInspector) handleSocket(rw http.ResponseWriter, r *http.Request) {
+	upgrader := websocket.Upgrader{} // same-origin only
+	conn, err := upgrader.Upgrade(rw, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	ch, backlog := w.hub.subscribe()
+	defer w.hub.unsubscribe(ch)
+
+	// Read only to notice when the browser goes away
+	gone := make(chan struct{})
+	go func() {
+		defer close(gone)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for _, data := range backlog {
+		if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+			return
+		}
+	}
+	ping := time.NewTicker(30 * time.Second)
+	defer ping.Stop()
+	for {
+		var err error
+		select {
+		case <-gone:
+			return
+		case data := <-ch:
+			err = conn.WriteMessage(websocket.TextMessage, data)
+		case <-ping.C:
+			err = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(5*time.Second))
+		}
+		if err != nil {
+			return
+		}
+	}
+}
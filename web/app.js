@@ -0,0 +1,304 @@
+// Web inspector: lists the catalog, builds call forms from the JSON
+// schemas and shows the JSON-RPC message log streamed over a websocket.
+"use strict";
+
+const token = new URLSearchParams(location.search).get("token") || "";
+const $ = (sel) => document.querySelector(sel);
+
+let catalog = { tools: [], resources: [], prompts: [] };
+let tab = "tools";
+let selected = null;
+
+function el(tag, attrs, ...children) {
+  const node = document.createElement(tag);
+  for (const [k, v] of Object.entries(attrs || {})) {
+    if (k === "class") node.className = v;
+    else if (k.startsWith("on")) node.addEventListener(k.slice(2), v);
+    else node.setAttribute(k, v);
+  }
+  for (const c of children) {
+    if (c != null) node.append(c);
+  }
+  return node;
+}
+
+async function api(path, body) {
+  const res = await fetch(path, {
+    method: body ? "POST" : "GET",
+    headers: { "X-Inspector-Token": token, "Content-Type": "application/json" },
+    body: body ? JSON.stringify(body) : undefined,
+  });
+  const data = await res.json().catch(() => ({ error: res.statusText }));
+  if (!res.ok && !data.confirm) throw new Error(data.error || res.statusText);
+  return data;
+}
+
+async function loadCatalog() {
+  try {
+    const data = await api("/api/catalog");
+    catalog = {
+      tools: data.tools || [],
+      resources: data.resources || [],
+      prompts: data.prompts || [],
+    };
+    $("#server").textContent = data.server + (data.info ? "  (" + data.info + ")" : "");
+    for (const [kind, msg] of Object.entries(data.errors || {})) {
+      addLog({ time: "", kind: "log", text: "List " + kind + ": " + msg });
+    }
+  } catch (err) {
+    $("#server").textContent = "Error: " + err.message;
+  }
+  renderList();
+}
+
+function itemName(item) {
+  return tab === "resources" ? item.uri : item.name;
+}
+
+function renderList() {
+  const list = $("#items");
+  const filter = $("#filter").value.toLowerCase();
+  list.replaceChildren();
+  const items = catalog[tab].filter((item) => itemName(item).toLowerCase().includes(filter));
+  if (items.length === 0) {
+    list.append(el("li", { class: "empty" }, "Nothing here."));
+  }
+  for (const item of items) {
+    const li = el("li", { onclick: () => select(item) }, itemName(item));
+    if (selected && itemName(selected) === itemName(item)) li.classList.add("selected");
+    list.append(li);
+  }
+}
+
+function select(item) {
+  selected = item;
+  renderList();
+  const detail = $("#detail");
+  detail.replaceChildren();
+  if (tab === "tools") detail.append(...toolDetail(item));
+  else if (tab === "resources") detail.append(...resourceDetail(item));
+  else detail.append(...promptDetail(item));
+}
+
+function toolDetail(tool) {
+  const hints = tool.annotations || {};
+  const title = el("h2", {}, tool.name);
+  if (hints.readOnlyHint) title.append(el("span", { class: "badge" }, "read-only"));
+  if (hints.destructiveHint) title.append(el("span", { class: "badge destructive" }, "destructive"));
+  const schema = tool.inputSchema || {};
+  const required = schema.required || [];
+  const fields = Object.entries(schema.properties || {}).map(([name, prop]) =>
+    field(name, prop || {}, required.includes(name)));
+  return [
+    title,
+    el("p", {}, tool.description || ""),
+    callForm(fields, (args) => call("tool", tool.name, args)),
+  ];
+}
+
+function resourceDetail(res) {
+  return [
+    el("h2", {}, res.name || res.uri),
+    el("p", { class: "muted" }, res.uri + (res.mimeType ? "  " + res.mimeType : "")),
+    el("p", {}, res.description || ""),
+    el("button", { onclick: () => call("resource", res.uri, {}) }, "Read"),
+  ];
+}
+
+function promptDetail(prompt) {
+  const fields = (prompt.arguments || []).map((arg) =>
+    field(arg.name, { type: "string", description: arg.description }, arg.required));
+  return [
+    el("h2", {}, prompt.name),
+    el("p", {}, prompt.description || ""),
+    callForm(fields, (args) => call("prompt", prompt.name, args)),
+  ];
+}
+
+// field renders an input for one schema property and returns it with a
+// function reading its value, or throwing when the value is invalid.
+function field(name, prop, required) {
+  const type = Array.isArray(prop.type) ? prop.type[0] : prop.type || "string";
+  let input;
+  if (prop.enum) {
+    input = el("select", {}, el("option", { value: "" }, ""),
+      ...prop.enum.map((v) => el("option", { value: JSON.stringify(v) }, String(v))));
+  } else if (type === "boolean") {
+    input = el("select", {}, el("option", { value: "" }, ""),
+      el("option", { value: "true" }, "true"), el("option", { value: "false" }, "false"));
+  } else if (type === "object" || type === "array") {
+    input = el("textarea", { placeholder: type === "array" ? "[ ]" : "{ }" });
+  } else {
+    input = el("input", { type: type === "integer" || type === "number" ? "number" : "text" });
+    if (type === "integer") input.step = "1";
+    if (type === "number") input.step = "any";
+  }
+  if (prop.default !== undefined) {
+    input.value = typeof prop.default === "string" ? prop.default : JSON.stringify(prop.default);
+  }
+
+  const label = el("label", {}, name,
+    required ? el("span", { class: "req" }, " *") : null,
+    el("span", { class: "desc" }, " " + type + (prop.description ? " – " + prop.description : "")),
+    input);
+
+  const read = () => {
+    const raw = input.value;
+    if (raw === "") {
+      if (required) throw new Error(name + " is required");
+      return undefined;
+    }
+    if (prop.enum || type === "boolean") return JSON.parse(raw);
+    if (type === "integer" || type === "number") {
+      const n = Number(raw);
+      if (Number.isNaN(n) || (type === "integer" && !Number.isInteger(n))) {
+        throw new Error(name + " must be " + (type === "integer" ? "an integer" : "a number"));
+      }
+      return n;
+    }
+    if (type === "object" || type === "array") {
+      try {
+        return JSON.parse(raw);
+      } catch (err) {
+        throw new Error(name + " must be JSON: " + err.message);
+      }
+    }
+    return raw;
+  };
+  return { name, label, read };
+}
+
+function callForm(fields, submit) {
+  const error = el("p", { class: "error" });
+  const form = el("form", {}, ...fields.map((f) => f.label), el("button", { type: "submit" }, "Call"), error);
+  form.addEventListener("submit", (ev) => {
+    ev.preventDefault();
+    error.textContent = "";
+    const args = {};
+    try {
+      for (const f of fields) {
+        const v = f.read();
+        if (v !== undefined) args[f.name] = v;
+      }
+    } catch (err) {
+      error.textContent = err.message;
+      return;
+    }
+    submit(args);
+  });
+  return form;
+}
+
+async function call(kind, name, args, confirmed) {
+  const out = $("#result");
+  out.replaceChildren(el("p", { class: "muted" }, "Running " + kind + " " + name + "…"));
+  let data;
+  try {
+    data = await api("/api/call", { kind, name, args, confirmed: !!confirmed });
+  } catch (err) {
+    out.replaceChildren(el("p", { class: "error" }, err.message));
+    return;
+  }
+  if (data.confirm) {
+    if (window.confirm(data.confirm + "\n\n" + JSON.stringify(args, null, 2))) {
+      call(kind, name, args, true);
+    } else {
+      out.replaceChildren(el("p", { class: "muted" }, "Cancelled."));
+    }
+    return;
+  }
+  const head = el("h2", {}, kind + " " + name, el("span", { class: "badge" }, data.durationMs + " ms"));
+  if (data.error) {
+    out.replaceChildren(head, el("p", { class: "error" }, data.error));
+    return;
+  }
+  if (data.result && data.result.isError) head.append(el("span", { class: "badge destructive" }, "error"));
+  out.replaceChildren(head, el("div", { class: "tree" }, tree(null, expandText(data.result), 0)));
+}
+
+// expandText replaces text that holds JSON with the parsed value, so
+// results from tools that return JSON as text fold like the rest.
+function expandText(v) {
+  if (typeof v === "string") {
+    const s = v.trim();
+    if (s.startsWith("{") || s.startsWith("[")) {
+      try {
+        return JSON.parse(s);
+      } catch (err) {
+        return v;
+      }
+    }
+    return v;
+  }
+  if (Array.isArray(v)) return v.map(expandText);
+  if (v && typeof v === "object") {
+    const out = {};
+    for (const [k, x] of Object.entries(v)) out[k] = expandText(x);
+    return out;
+  }
+  return v;
+}
+
+// tree renders a value as nested <details>; the first two levels open.
+function tree(key, v, depth) {
+  const keyNode = key === null ? null : el("span", { class: "key" }, key + ": ");
+  if (v && typeof v === "object") {
+    const entries = Array.isArray(v) ? v.map((x, i) => [i, x]) : Object.entries(v);
+    const summary = Array.isArray(v) ? "[" + entries.length + " items]" : "{" + entries.length + " keys}";
+    const node = el("details", {}, el("summary", {}, keyNode, summary));
+    node.open = depth < 2;
+    for (const [k, x] of entries) node.append(tree(String(k), x, depth + 1));
+    return node;
+  }
+  const type = v === null ? "null" : typeof v;
+  return el("div", { class: "leaf" }, keyNode, el("span", { class: type }, JSON.stringify(v)));
+}
+
+function addLog(ev) {
+  const log = $("#log");
+  const atBottom = log.scrollTop + log.clientHeight >= log.scrollHeight - 4;
+  let summary = ev.text;
+  let body = null;
+  if (ev.kind !== "log") {
+    try {
+      const msg = JSON.parse(ev.text);
+      summary = msg.method || (msg.error ? "error" : "result");
+      if (msg.id !== undefined) summary += "  #" + msg.id;
+      if (msg.method && msg.method.endsWith("/list_changed") && ev.kind === "recv") loadCatalog();
+      body = el("pre", {}, JSON.stringify(msg, null, 2));
+    } catch (err) {
+      body = el("pre", {}, ev.text);
+    }
+  }
+  log.append(el("li", { class: ev.kind },
+    el("details", {}, el("summary", {}, el("span", { class: "time" }, ev.time), summary), body)));
+  while (log.children.length > 500) log.firstChild.remove();
+  if (atBottom) log.scrollTop = log.scrollHeight;
+}
+
+function connectLog() {
+  const proto = location.protocol === "https:" ? "wss:" : "ws:";
+  const ws = new WebSocket(proto + "//" + location.host + "/api/ws?token=" + encodeURIComponent(token));
+  ws.onopen = () => { $("#socket").textContent = "live"; };
+  ws.onmessage = (msg) => addLog(JSON.parse(msg.data));
+  ws.onclose = () => {
+    $("#socket").textContent = "disconnected, retrying…";
+    setTimeout(connectLog, 2000);
+  };
+}
+
+for (const button of document.querySelectorAll("#tabs button")) {
+  button.addEventListener("click", () => {
+    document.querySelectorAll("#tabs button").forEach((b) => b.classList.remove("active"));
+    button.classList.add("active");
+    tab = button.dataset.tab;
+    selected = null;
+    renderList();
+  });
+}
+$("#filter").addEventListener("input", renderList);
+$("#reload").addEventListener("click", loadCatalog);
+$("#clear").addEventListener("click", () => $("#log").replaceChildren());
+
+loadCatalog();
+connectLog();
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ThinkInAIXYZ/go-mcp/transport"
+	"github.com/gorilla/websocket"
+)
+
+// WebSocket keepalive: a ping every wsPingInterval, and the connection is
+// considered dead when nothing, not even a pong, arrives for wsPongWait.
+const (
+	wsPingInterval = 30 * time.Second
+	wsPongWait     = 2 * wsPingInterval
+	wsWriteWait    = 10 * time.Second
+)
+
+// wsTransport carries JSON-RPC over a WebSocket, one message per frame.
+// When the connection drops it tells the mux through its close hook.
+type wsTransport struct {
+	closeHook
+	url    string
+	dialer websocket.Dialer
+	opts   dialOptions
+
+	conn     *websocket.Conn
+	receiver transport.ClientReceiver
+	writeMu  sync.Mutex // gorilla allows one writer at a time
+	done     chan struct{}
+	once     sync.Once
+}
+
+func newWSTransport(url string, opts dialOptions) *wsTransport {
+	return &wsTransport{
+		url:    url,
+		dialer: websocket.Dialer{HandshakeTimeout: 30 * time.Second, TLSClientConfig: opts.tls},
+		opts:   opts,
+		done:   make(chan struct{}),
+	}
+}
+
+func (t *wsTransport) SetReceiver(r transport.ClientReceiver) {
+	t.receiver = r
+}
+
+// Start dials the server and starts reading and pinging.
+func (t *wsTransport) Start() error {
+	ctx, cancel := context.WithTimeout(context.Background(), t.dialer.HandshakeTimeout)
+	defer cancel()
+	conn, resp, err := t.dialer.DialContext(ctx, t.url, t.opts.headers)
+	if err != nil {
+		if resp != nil {
+			return fmt.Errorf("websocket handshake: %s: %w", resp.Status, err)
+		}
+		return fmt.Errorf("websocket handshake: %w", err)
+	}
+	t.conn = conn
+
+	conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+	go t.readLoop()
+	go t.pingLoop()
+	return nil
+}
+
+func (t *wsTransport) readLoop() {
+	for {
+		_, data, err := t.conn.ReadMessage()
+		if err != nil {
+			select {
+			case <-t.done:
+			default:
+				log.Printf("WebSocket connection to %s lost: %v", t.url, err)
+				t.Close()
+			}
+			t.fire(err)
+			return
+		}
+		t.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+		if t.receiver != nil {
+			t.receiver.Receive(context.Background(), data)
+		}
+	}
+}
+
+func (t *wsTransport) pingLoop() {
+	ticker := time.NewTicker(wsPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.done:
+			return
+		case <-ticker.C:
+			t.writeMu.Lock()
+			err := t.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait))
+			t.writeMu.Unlock()
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Send writes msg as one text frame.
+func (t *wsTransport) Send(ctx context.Context, msg transport.Message) error {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(wsWriteWait)
+	}
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	select {
+	case <-t.done:
+		return errMuxClosed
+	default:
+	}
+	t.conn.SetWriteDeadline(deadline)
+	return t.conn.WriteMessage(websocket.TextMessage, msg)
+}
+
+// Close says goodbye to the server and closes the connection.
+func (t *wsTransport) Close() error {
+	var err error
+	t.once.Do(func() {
+		close(t.done)
+		if t.conn == nil {
+			return
+		}
+		t.writeMu.Lock()
+		t.conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+		t.writeMu.Unlock()
+		err = t.conn.Close()
+	})
+	return err
+}
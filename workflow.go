@@ -0,0 +1,600 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// workflow is a YAML file of steps run by the "run" command:
+//
+//	name: distinct values per table
+//	vars:
+//	  database: demo
+//	steps:
+//	  - id: tables
+//	    tool: query
+//	    arguments:
+//	      query: "SELECT TableName FROM dbc.tablesv WHERE DatabaseName = '{{ .vars.database }}'"
+//	    extract:
+//	      tables: $.results[*].TableName
+//	  - id: distinct
+//	    foreach: "{{ .vars.tables }}"
+//	    as: table
+//	    tool: list_distinct_values
+//	    arguments:
+//	      table_name: "{{ .vars.database }}.{{ .table }}"
+//	    retry: {attempts: 3, delay: 2s}
+//	report: |
+//	  {{ range .steps.distinct }}{{ .text }}
+//	  {{ end }}
+//
+// String values are Go text/template templates over .vars, .steps and,
+// inside a loop, the loop variable and .index. A value that is a single
+// {{ }} action keeps the type of what it evaluates to, so lists and
+// numbers can be passed through unchanged.
+type workflow struct {
+	Name   string                 `yaml:"name"`
+	Vars   map[string]interface{} `yaml:"vars"`
+	Steps  []workflowStep         `yaml:"steps"`
+	Report string                 `yaml:"report"`
+}
+
+// workflowStep runs one tool call, resource read or prompt, optionally
+// once per item of a list.
+type workflowStep struct {
+	ID        string                 `yaml:"id"`
+	Tool      string                 `yaml:"tool"`
+	Resource  string                 `yaml:"resource"`
+	Prompt    string                 `yaml:"prompt"`
+	Arguments map[string]interface{} `yaml:"arguments"`
+
+	// Extract binds workflow variables to JSON paths into the step's
+	// result. In a loop each variable holds one value per iteration.
+	Extract map[string]string `yaml:"extract"`
+	// When skips the step unless it renders to a true value.
+	When string `yaml:"when"`
+	// Foreach runs the step once per element of the list it renders to.
+	Foreach string `yaml:"foreach"`
+	// As names the loop variable. The default is "item".
+	As string `yaml:"as"`
+
+	Retry           *retrySpec `yaml:"retry"`
+	ContinueOnError bool       `yaml:"continueOnError"`
+}
+
+// retrySpec retries a step whose call fails or returns a tool error.
+type retrySpec struct {
+	Attempts int     `yaml:"attempts"`
+	Delay    string  `yaml:"delay"`
+	Backoff  float64 `yaml:"backoff"`
+}
+
+// loadWorkflow parses and checks a workflow file.
+func loadWorkflow(path string) (*workflow, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	wf := &workflow{}
+	if err := dec.Decode(wf); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	seen := map[string]bool{}
+	for i, step := range wf.Steps {
+		if step.ID == "" {
+			return nil, fmt.Errorf("%s: step %d has no id", path, i+1)
+		}
+		if seen[step.ID] {
+			return nil, fmt.Errorf("%s: duplicate step id %q", path, step.ID)
+		}
+		seen[step.ID] = true
+		kinds := 0
+		for _, s := range []string{step.Tool, step.Resource, step.Prompt} {
+			if s != "" {
+				kinds++
+			}
+		}
+		if kinds != 1 {
+			return nil, fmt.Errorf("%s: step %q needs exactly one of tool, resource or prompt", path, step.ID)
+		}
+		if step.Retry != nil && step.Retry.Delay != "" {
+			if _, err := time.ParseDuration(step.Retry.Delay); err != nil {
+				return nil, fmt.Errorf("%s: step %q: bad retry delay: %w", path, step.ID, err)
+			}
+		}
+	}
+	return wf, nil
+}
+
+// workflowRun holds the state of one workflow execution.
+type workflowRun struct {
+	sess  *session
+	vars  map[string]interface{}
+	steps map[string]interface{}
+}
+
+// runWorkflowCommand implements "run [flags] FILE".
+func runWorkflowCommand(a *app, url string, args []string) error {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	vars := argList{}
+	fs.Var(vars, "var", "Set a workflow variable as key=value. Can be used multiple times.")
+	reportPath := fs.String("report", "", "Write the report to this file instead of stdout")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: run [flags] WORKFLOW.yaml")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("run: expected one workflow file")
+	}
+
+	wf, err := loadWorkflow(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	sess, err := a.connect(url)
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	run := &workflowRun{sess: sess, vars: map[string]interface{}{}, steps: map[string]interface{}{}}
+	for k, v := range wf.Vars {
+		run.vars[k] = v
+	}
+	for k, v := range vars {
+		run.vars[k] = v
+	}
+
+	ctx := context.Background()
+	for _, step := range wf.Steps {
+		if err := run.runStep(ctx, step); err != nil {
+			return fmt.Errorf("step %s: %w", step.ID, err)
+		}
+	}
+
+	if wf.Report == "" {
+		return nil
+	}
+	report, err := run.render(wf.Report, nil)
+	if err != nil {
+		return fmt.Errorf("report: %w", err)
+	}
+	report = a.redact.Text(report)
+	if *reportPath != "" {
+		return os.WriteFile(*reportPath, []byte(report), 0o644)
+	}
+	fmt.Print(report)
+	return nil
+}
+
+// data returns the template context, with loop bindings on top.
+func (r *workflowRun) data(loop map[string]interface{}) map[string]interface{} {
+	d := map[string]interface{}{"vars": r.vars, "steps": r.steps}
+	for k, v := range loop {
+		d[k] = v
+	}
+	return d
+}
+
+// runStep evaluates the step's condition and loop, then runs it.
+func (r *workflowRun) runStep(ctx context.Context, step workflowStep) error {
+	if step.When != "" {
+		ok, err := r.truthy(step.When, nil)
+		if err != nil {
+			return fmt.Errorf("when: %w", err)
+		}
+		if !ok {
+			log.Printf("Skipping step %s", step.ID)
+			return nil
+		}
+	}
+
+	if step.Foreach == "" {
+		out, err := r.execute(ctx, step, nil)
+		if err != nil {
+			return err
+		}
+		r.steps[step.ID] = out
+		for name, path := range step.Extract {
+			value, err := jsonPathGet(out["result"], path)
+			if err != nil {
+				return fmt.Errorf("extract %s: %w", name, err)
+			}
+			r.vars[name] = value
+		}
+		return nil
+	}
+
+	items, err := r.evaluate(step.Foreach, nil)
+	if err != nil {
+		return fmt.Errorf("foreach: %w", err)
+	}
+	list, ok := toList(items)
+	if !ok {
+		return fmt.Errorf("foreach: %T is not a list", items)
+	}
+	as := step.As
+	if as == "" {
+		as = "item"
+	}
+
+	outs := make([]interface{}, 0, len(list))
+	extracted := map[string][]interface{}{}
+	for i, item := range list {
+		loop := map[string]interface{}{as: item, "index": i}
+		out, err := r.execute(ctx, step, loop)
+		if err != nil {
+			return fmt.Errorf("item %d: %w", i, err)
+		}
+		outs = append(outs, out)
+		for name, path := range step.Extract {
+			value, err := jsonPathGet(out["result"], path)
+			if err != nil {
+				return fmt.Errorf("extract %s: %w", name, err)
+			}
+			extracted[name] = append(extracted[name], value)
+		}
+	}
+	r.steps[step.ID] = outs
+	for name, values := range extracted {
+		r.vars[name] = values
+	}
+	return nil
+}
+
+// execute runs one call of a step with retries and returns its output:
+// result (decoded JSON or text), text, isError, error and, for row data,
+// rows and table.
+func (r *workflowRun) execute(ctx context.Context, step workflowStep, loop map[string]interface{}) (map[string]interface{}, error) {
+	args, err := r.renderValue(step.Arguments, loop)
+	if err != nil {
+		return nil, fmt.Errorf("arguments: %w", err)
+	}
+	argMap, _ := args.(map[string]interface{})
+
+	attempts, delay, backoff := 1, time.Second, 1.0
+	if step.Retry != nil {
+		if step.Retry.Attempts > 1 {
+			attempts = step.Retry.Attempts
+		}
+		if step.Retry.Delay != "" {
+			delay, _ = time.ParseDuration(step.Retry.Delay)
+		}
+		if step.Retry.Backoff > 0 {
+			backoff = step.Retry.Backoff
+		}
+	}
+
+	var out map[string]interface{}
+	for attempt := 1; ; attempt++ {
+		out, err = r.call(ctx, step, argMap, loop)
+		failed := err != nil || out["isError"] == true
+		if !failed || attempt >= attempts {
+			break
+		}
+		log.Printf("Step %s failed (attempt %d of %d), retrying in %s", step.ID, attempt, attempts, delay)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		delay = time.Duration(float64(delay) * backoff)
+	}
+
+	if err != nil {
+		if !step.ContinueOnError {
+			return nil, err
+		}
+		log.Printf("Step %s failed, continuing: %v", step.ID, err)
+		return map[string]interface{}{"isError": true, "error": err.Error()}, nil
+	}
+	if out["isError"] == true && !step.ContinueOnError {
+		return nil, fmt.Errorf("tool returned an error: %s", abbreviate(fmt.Sprint(out["text"]), 200))
+	}
+	return out, nil
+}
+
+// call performs the step's request once.
+func (r *workflowRun) call(ctx context.Context, step workflowStep, args map[string]interface{}, loop map[string]interface{}) (map[string]interface{}, error) {
+	switch {
+	case step.Tool != "":
+		name, err := r.render(step.Tool, loop)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Step %s: calling %s", step.ID, name)
+		result, err := r.sess.CallTool(ctx, name, args)
+		if err != nil {
+			return nil, err
+		}
+		out := map[string]interface{}{
+			"result":  resultValue(result),
+			"text":    resultText(result),
+			"isError": result.IsError,
+		}
+		if t, ok := resultTable(result); ok {
+			out["rows"] = tableMaps(t)
+			var b strings.Builder
+			renderTable(&b, t)
+			out["table"] = b.String()
+		}
+		return out, nil
+
+	case step.Resource != "":
+		uri, err := r.render(step.Resource, loop)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Step %s: reading %s", step.ID, uri)
+		contents, err := r.sess.ReadResource(ctx, uri)
+		if err != nil {
+			return nil, err
+		}
+		var texts []string
+		for _, c := range contents {
+			texts = append(texts, c.Text)
+		}
+		text := strings.Join(texts, "\n")
+		var value interface{} = text
+		var v interface{}
+		if json.Unmarshal([]byte(text), &v) == nil {
+			value = v
+		}
+		return map[string]interface{}{"result": value, "text": text, "isError": false}, nil
+
+	default:
+		name, err := r.render(step.Prompt, loop)
+		if err != nil {
+			return nil, err
+		}
+		promptArgs := map[string]string{}
+		for k, v := range args {
+			promptArgs[k] = fmt.Sprint(v)
+		}
+		log.Printf("Step %s: getting prompt %s", step.ID, name)
+		prompt, err := r.sess.GetPrompt(ctx, name, promptArgs)
+		if err != nil {
+			return nil, err
+		}
+		var texts []string
+		messages := make([]interface{}, 0, len(prompt.Messages))
+		for _, m := range prompt.Messages {
+			texts = append(texts, m.Content.Text)
+			messages = append(messages, map[string]interface{}{"role": m.Role, "text": m.Content.Text})
+		}
+		return map[string]interface{}{
+			"result":      messages,
+			"text":        strings.Join(texts, "\n"),
+			"description": prompt.Description,
+			"isError":     false,
+		}, nil
+	}
+}
+
+// tableMaps converts table rows to maps keyed by column name.
+func tableMaps(t *table) []interface{} {
+	rows := make([]interface{}, len(t.Rows))
+	for i, row := range t.Rows {
+		m := make(map[string]interface{}, len(row))
+		for j, c := range t.Columns {
+			m[c.Name] = row[j]
+		}
+		rows[i] = m
+	}
+	return rows
+}
+
+// workflowFuncs are available in every workflow template.
+var workflowFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"join": func(sep string, v interface{}) string {
+		list, _ := toList(v)
+		parts := make([]string, len(list))
+		for i, item := range list {
+			parts[i] = fmt.Sprint(item)
+		}
+		return strings.Join(parts, sep)
+	},
+	"default": func(def, v interface{}) interface{} {
+		if v == nil || v == "" {
+			return def
+		}
+		return v
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"sqlquote": func(v interface{}) string {
+		return "'" + strings.ReplaceAll(fmt.Sprint(v), "'", "''") + "'"
+	},
+}
+
+// singleAction matches a string that is exactly one template action.
+var singleAction = regexp.MustCompile(`^\s*\{\{-?\s*(.*?)\s*-?\}\}\s*$`)
+
+// render executes text as a template and returns the output.
+func (r *workflowRun) render(text string, loop map[string]interface{}) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+	tmpl, err := template.New("").Funcs(workflowFuncs).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, r.data(loop)); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// evaluate returns the value of text. A single {{ }} action yields the
+// value of its pipeline with its type intact; anything else renders to a
+// string.
+func (r *workflowRun) evaluate(text string, loop map[string]interface{}) (interface{}, error) {
+	m := singleAction.FindStringSubmatch(text)
+	if m == nil || strings.Contains(m[1], "{{") {
+		return r.render(text, loop)
+	}
+
+	var captured interface{}
+	funcs := template.FuncMap{"capture__": func(v interface{}) string {
+		captured = v
+		return ""
+	}}
+	tmpl, err := template.New("").Funcs(workflowFuncs).Funcs(funcs).Option("missingkey=zero").
+		Parse("{{ capture__ (" + m[1] + ") }}")
+	if err != nil {
+		return nil, err
+	}
+	if err := tmpl.Execute(&bytes.Buffer{}, r.data(loop)); err != nil {
+		return nil, err
+	}
+	return captured, nil
+}
+
+// renderValue evaluates every string inside a YAML value.
+func (r *workflowRun) renderValue(v interface{}, loop map[string]interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case string:
+		return r.evaluate(v, loop)
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			rendered, err := r.renderValue(item, loop)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", k, err)
+			}
+			out[k] = rendered
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			rendered, err := r.renderValue(item, loop)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = rendered
+		}
+		return out, nil
+	}
+	return v, nil
+}
+
+// truthy evaluates a condition. False, nil, zero, empty strings and
+// collections, "false" and "0" are false.
+func (r *workflowRun) truthy(text string, loop map[string]interface{}) (bool, error) {
+	v, err := r.evaluate(text, loop)
+	if err != nil {
+		return false, err
+	}
+	if s, ok := v.(string); ok {
+		s = strings.TrimSpace(s)
+		if b, err := strconv.ParseBool(s); err == nil {
+			return b, nil
+		}
+		return s != "" && s != "0" && s != "<no value>", nil
+	}
+	if v == nil {
+		return false, nil
+	}
+	return !reflect.ValueOf(v).IsZero() && !isEmptyCollection(v), nil
+}
+
+func isEmptyCollection(v interface{}) bool {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return rv.Len() == 0
+	}
+	return false
+}
+
+// toList converts any slice to []interface{}.
+func toList(v interface{}) ([]interface{}, bool) {
+	if list, ok := v.([]interface{}); ok {
+		return list, true
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, false
+	}
+	list := make([]interface{}, rv.Len())
+	for i := range list {
+		list[i] = rv.Index(i).Interface()
+	}
+	return list, true
+}
+
+// jsonPathGet evaluates a JSON path against a decoded value. "$" is the
+// whole value. Paths with a wildcard return a list of every match; other
+// paths return the single match, or nil when it is missing.
+func jsonPathGet(v interface{}, path string) (interface{}, error) {
+	if strings.TrimSpace(path) == "$" {
+		return v, nil
+	}
+	segs, err := parseJSONPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := []interface{}{v}
+	wildcard := false
+	for _, seg := range segs {
+		var next []interface{}
+		for _, m := range matches {
+			switch m := m.(type) {
+			case map[string]interface{}:
+				if seg == "*" {
+					for _, item := range m {
+						next = append(next, item)
+					}
+				} else if item, ok := m[seg]; ok {
+					next = append(next, item)
+				}
+			case []interface{}:
+				if seg == "*" {
+					next = append(next, m...)
+				} else if i, err := strconv.Atoi(seg); err == nil && i >= 0 && i < len(m) {
+					next = append(next, m[i])
+				}
+			}
+		}
+		wildcard = wildcard || seg == "*"
+		matches = next
+	}
+
+	if wildcard {
+		if matches == nil {
+			matches = []interface{}{}
+		}
+		return matches, nil
+	}
+	if len(matches) == 0 {
+		return nil, nil
+	}
+	return matches[0], nil
+}